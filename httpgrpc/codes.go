@@ -0,0 +1,109 @@
+package httpgrpc
+
+import (
+	"net/http"
+
+	spb "github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+)
+
+// StatusClientClosedRequest is the non-standard HTTP status code used when the
+// client went away before the response was sent.
+const StatusClientClosedRequest = 499
+
+// GRPCCodeFromHTTPStatus maps an HTTP status code to the gRPC code closest in
+// meaning. It never returns OK, as it's used for errors.
+func GRPCCodeFromHTTPStatus(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case StatusClientClosedRequest:
+		return codes.Canceled
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch code / 100 {
+	case 4:
+		return codes.FailedPrecondition
+	case 5:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// HTTPStatusFromGRPCCode maps a gRPC code to the HTTP status code closest in
+// meaning. Codes outside the range of gRPC codes are taken to be HTTP status
+// codes, as sent by older peers; see LegacyError.
+func HTTPStatusFromGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return StatusClientClosedRequest
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	}
+	if code >= 100 && code < 600 {
+		return int(code)
+	}
+	return http.StatusInternalServerError
+}
+
+// LegacyError converts an httpgrpc error to use its HTTP status code as its
+// gRPC code, as errors did before they were mapped with
+// GRPCCodeFromHTTPStatus, for peers which depend on that. Other errors are
+// returned unchanged.
+func LegacyError(err error) error {
+	resp, ok := HTTPResponseFromError(err)
+	if !ok {
+		return err
+	}
+	s, _ := status.FromError(err)
+	p := s.Proto()
+	return status.ErrorProto(&spb.Status{
+		Code:    resp.Code,
+		Message: p.Message,
+		Details: p.Details,
+	})
+}
@@ -0,0 +1,46 @@
+package httpgrpc
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCodes(t *testing.T) {
+	for _, tc := range []struct {
+		http int
+		grpc codes.Code
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{StatusClientClosedRequest, codes.Canceled},
+		{http.StatusInternalServerError, codes.Internal},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusGatewayTimeout, codes.DeadlineExceeded},
+	} {
+		err := Errorf(tc.http, "fail")
+		require.Equal(t, tc.grpc, status.Code(err))
+		require.Equal(t, tc.http, HTTPStatusFromGRPCCode(tc.grpc))
+
+		// The HTTP code is kept in the response, whichever gRPC code is used.
+		resp, ok := HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, int32(tc.http), resp.Code)
+
+		legacy := LegacyError(err)
+		require.Equal(t, codes.Code(tc.http), status.Code(legacy))
+		resp, ok = HTTPResponseFromError(legacy)
+		require.True(t, ok)
+		require.Equal(t, int32(tc.http), resp.Code)
+		require.Equal(t, tc.http, HTTPStatusFromGRPCCode(status.Code(legacy)))
+	}
+
+	// Unmapped statuses fall back on their class; non-error ones are never OK.
+	require.Equal(t, codes.FailedPrecondition, GRPCCodeFromHTTPStatus(http.StatusTeapot))
+	require.Equal(t, codes.Internal, GRPCCodeFromHTTPStatus(http.StatusHTTPVersionNotSupported))
+	require.Equal(t, codes.Unknown, GRPCCodeFromHTTPStatus(http.StatusOK))
+}
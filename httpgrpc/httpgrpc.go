@@ -2,8 +2,10 @@ package httpgrpc
 
 import (
 	"fmt"
+	"time"
 
 	spb "github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	"github.com/gogo/status"
 	log "github.com/sirupsen/logrus"
@@ -19,17 +21,41 @@ func Errorf(code int, tmpl string, args ...interface{}) error {
 	})
 }
 
+// ErrorfWithDetails is like Errorf, but also attaches typed details to the
+// error; see ErrorFromHTTPResponseWithDetails.
+func ErrorfWithDetails(code int, details []proto.Message, tmpl string, args ...interface{}) error {
+	return ErrorFromHTTPResponseWithDetails(&HTTPResponse{
+		Code: int32(code),
+		Body: []byte(fmt.Sprintf(tmpl, args...)),
+	}, details...)
+}
+
 // ErrorFromHTTPResponse converts an HTTP response into a grpc error
 func ErrorFromHTTPResponse(resp *HTTPResponse) error {
-	a, err := types.MarshalAny(resp)
-	if err != nil {
-		return err
+	return ErrorFromHTTPResponseWithDetails(resp)
+}
+
+// ErrorFromHTTPResponseWithDetails converts an HTTP response into a grpc error,
+// with typed details, such as *rpc.ErrorInfo, *rpc.RetryInfo, *rpc.QuotaFailure
+// or *rpc.BadRequest, which can be read back with Details. The gRPC code is
+// given by GRPCCodeFromHTTPStatus; the HTTP code travels in the response.
+//
+// Clients from before details were supported only understand errors without
+// them, and will treat these as plain gRPC errors.
+func ErrorFromHTTPResponseWithDetails(resp *HTTPResponse, details ...proto.Message) error {
+	anys := make([]*types.Any, 0, 1+len(details))
+	for _, pb := range append([]proto.Message{resp}, details...) {
+		a, err := types.MarshalAny(pb)
+		if err != nil {
+			return err
+		}
+		anys = append(anys, a)
 	}
 
 	return status.ErrorProto(&spb.Status{
-		Code:    resp.Code,
+		Code:    int32(GRPCCodeFromHTTPStatus(int(resp.Code))),
 		Message: string(resp.Body),
-		Details: []*types.Any{a},
+		Details: anys,
 	})
 }
 
@@ -40,12 +66,13 @@ func HTTPResponseFromError(err error) (*HTTPResponse, bool) {
 		return nil, false
 	}
 
+	// The response is always the first detail.
 	status := s.Proto()
-	if len(status.Details) != 1 {
+	var resp HTTPResponse
+	if len(status.Details) == 0 || !types.Is(status.Details[0], &resp) {
 		return nil, false
 	}
 
-	var resp HTTPResponse
 	if err := types.UnmarshalAny(status.Details[0], &resp); err != nil {
 		log.Errorf("Got error containing non-response: %v", err)
 		return nil, false
@@ -53,3 +80,30 @@ func HTTPResponseFromError(err error) (*HTTPResponse, bool) {
 
 	return &resp, true
 }
+
+// Details returns the typed details attached to an httpgrpc error, skipping any
+// of types which aren't linked into this binary.
+func Details(err error) []proto.Message {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	var details []proto.Message
+	for _, a := range s.Proto().Details {
+		if types.Is(a, &HTTPResponse{}) {
+			continue
+		}
+		var detail types.DynamicAny
+		if err := types.UnmarshalAny(a, &detail); err != nil {
+			continue
+		}
+		details = append(details, detail.Message)
+	}
+	return details
+}
+
+// RetryInfo is a detail telling clients how long to wait before retrying.
+func RetryInfo(delay time.Duration) *spb.RetryInfo {
+	return &spb.RetryInfo{RetryDelay: types.DurationProto(delay)}
+}
@@ -31,10 +31,17 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type HTTPRequest struct {
-	Method  string    `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
-	Url     string    `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
-	Headers []*Header `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
-	Body    []byte    `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	Method     string    `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Url        string    `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Headers    []*Header `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body       []byte    `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	RemoteAddr string    `protobuf:"bytes,5,opt,name=remote_addr,json=remoteAddr,proto3" json:"remote_addr,omitempty"`
+	Host       string    `protobuf:"bytes,6,opt,name=host,proto3" json:"host,omitempty"`
+	Proto      string    `protobuf:"bytes,7,opt,name=proto,proto3" json:"proto,omitempty"`
+	Trailers   []*Header `protobuf:"bytes,8,rep,name=trailers,proto3" json:"trailers,omitempty"`
+	TLS        *TLSState `protobuf:"bytes,9,opt,name=tls,proto3" json:"tls,omitempty"`
+	// Encodings the client can decode response bodies from, in order of preference.
+	AcceptBodyEncodings []string `protobuf:"bytes,10,rep,name=accept_body_encodings,json=acceptBodyEncodings,proto3" json:"accept_body_encodings,omitempty"`
 }
 
 func (m *HTTPRequest) Reset()      { *m = HTTPRequest{} }
@@ -97,10 +104,55 @@ func (m *HTTPRequest) GetBody() []byte {
 	return nil
 }
 
+func (m *HTTPRequest) GetRemoteAddr() string {
+	if m != nil {
+		return m.RemoteAddr
+	}
+	return ""
+}
+
+func (m *HTTPRequest) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *HTTPRequest) GetProto() string {
+	if m != nil {
+		return m.Proto
+	}
+	return ""
+}
+
+func (m *HTTPRequest) GetTrailers() []*Header {
+	if m != nil {
+		return m.Trailers
+	}
+	return nil
+}
+
+func (m *HTTPRequest) GetTLS() *TLSState {
+	if m != nil {
+		return m.TLS
+	}
+	return nil
+}
+
+func (m *HTTPRequest) GetAcceptBodyEncodings() []string {
+	if m != nil {
+		return m.AcceptBodyEncodings
+	}
+	return nil
+}
+
 type HTTPResponse struct {
-	Code    int32     `protobuf:"varint,1,opt,name=Code,proto3" json:"Code,omitempty"`
-	Headers []*Header `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
-	Body    []byte    `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	Code     int32     `protobuf:"varint,1,opt,name=Code,proto3" json:"Code,omitempty"`
+	Headers  []*Header `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body     []byte    `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	Trailers []*Header `protobuf:"bytes,4,rep,name=trailers,proto3" json:"trailers,omitempty"`
+	// Set if the body is compressed, to one of the request's accept_body_encodings.
+	BodyEncoding string `protobuf:"bytes,5,opt,name=body_encoding,json=bodyEncoding,proto3" json:"body_encoding,omitempty"`
 }
 
 func (m *HTTPResponse) Reset()      { *m = HTTPResponse{} }
@@ -156,6 +208,20 @@ func (m *HTTPResponse) GetBody() []byte {
 	return nil
 }
 
+func (m *HTTPResponse) GetTrailers() []*Header {
+	if m != nil {
+		return m.Trailers
+	}
+	return nil
+}
+
+func (m *HTTPResponse) GetBodyEncoding() string {
+	if m != nil {
+		return m.BodyEncoding
+	}
+	return ""
+}
+
 type Header struct {
 	Key    string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	Values []string `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
@@ -207,37 +273,132 @@ func (m *Header) GetValues() []string {
 	return nil
 }
 
+// TLSState is the subset of tls.ConnectionState which handlers use.
+type TLSState struct {
+	Version            uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	CipherSuite        uint32 `protobuf:"varint,2,opt,name=cipher_suite,json=cipherSuite,proto3" json:"cipher_suite,omitempty"`
+	ServerName         string `protobuf:"bytes,3,opt,name=server_name,json=serverName,proto3" json:"server_name,omitempty"`
+	NegotiatedProtocol string `protobuf:"bytes,4,opt,name=negotiated_protocol,json=negotiatedProtocol,proto3" json:"negotiated_protocol,omitempty"`
+	// DER encoded, leaf first.
+	PeerCertificates [][]byte `protobuf:"bytes,5,rep,name=peer_certificates,json=peerCertificates,proto3" json:"peer_certificates,omitempty"`
+}
+
+func (m *TLSState) Reset()      { *m = TLSState{} }
+func (*TLSState) ProtoMessage() {}
+func (*TLSState) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6670c8e151665986, []int{3}
+}
+func (m *TLSState) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TLSState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TLSState.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TLSState) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TLSState.Merge(m, src)
+}
+func (m *TLSState) XXX_Size() int {
+	return m.Size()
+}
+func (m *TLSState) XXX_DiscardUnknown() {
+	xxx_messageInfo_TLSState.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TLSState proto.InternalMessageInfo
+
+func (m *TLSState) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *TLSState) GetCipherSuite() uint32 {
+	if m != nil {
+		return m.CipherSuite
+	}
+	return 0
+}
+
+func (m *TLSState) GetServerName() string {
+	if m != nil {
+		return m.ServerName
+	}
+	return ""
+}
+
+func (m *TLSState) GetNegotiatedProtocol() string {
+	if m != nil {
+		return m.NegotiatedProtocol
+	}
+	return ""
+}
+
+func (m *TLSState) GetPeerCertificates() [][]byte {
+	if m != nil {
+		return m.PeerCertificates
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*HTTPRequest)(nil), "httpgrpc.HTTPRequest")
 	proto.RegisterType((*HTTPResponse)(nil), "httpgrpc.HTTPResponse")
 	proto.RegisterType((*Header)(nil), "httpgrpc.Header")
+	proto.RegisterType((*TLSState)(nil), "httpgrpc.TLSState")
 }
 
 func init() { proto.RegisterFile("httpgrpc/httpgrpc.proto", fileDescriptor_6670c8e151665986) }
 
 var fileDescriptor_6670c8e151665986 = []byte{
-	// 322 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0x3f, 0x4f, 0xc2, 0x40,
-	0x14, 0xc0, 0x7b, 0x14, 0xab, 0x1c, 0x0c, 0xe4, 0xa2, 0xd8, 0x30, 0x5c, 0x08, 0x89, 0x09, 0x71,
-	0xa0, 0x09, 0x4e, 0x8e, 0xea, 0xc2, 0xa6, 0xb9, 0x30, 0xb9, 0xb5, 0xf4, 0xa5, 0x35, 0x50, 0x5e,
-	0x6d, 0xaf, 0x10, 0x36, 0x3f, 0x82, 0x1f, 0xc3, 0x8f, 0xe2, 0xc8, 0xc8, 0x28, 0xe5, 0x0b, 0xf8,
-	0x11, 0xcc, 0x5d, 0x29, 0x36, 0x4e, 0x6e, 0xbf, 0xf7, 0x27, 0xf7, 0x7b, 0xef, 0x1e, 0xbd, 0x0c,
-	0xa5, 0x8c, 0x83, 0x24, 0x9e, 0x3a, 0x25, 0x0c, 0xe3, 0x04, 0x25, 0xb2, 0xb3, 0x32, 0xee, 0x9e,
-	0x07, 0x18, 0xa0, 0x4e, 0x3a, 0x8a, 0x8a, 0x7a, 0x7f, 0x45, 0x9b, 0xe3, 0xc9, 0xe4, 0x49, 0xc0,
-	0x6b, 0x06, 0xa9, 0x64, 0x1d, 0x6a, 0x45, 0x20, 0x43, 0xf4, 0x6d, 0xd2, 0x23, 0x83, 0x86, 0x38,
-	0x44, 0xac, 0x4d, 0xcd, 0x2c, 0x99, 0xdb, 0x35, 0x9d, 0x54, 0xc8, 0xae, 0xe9, 0x69, 0x08, 0xae,
-	0x0f, 0x49, 0x6a, 0x9b, 0x3d, 0x73, 0xd0, 0x1c, 0xb5, 0x87, 0x47, 0xf5, 0x58, 0x17, 0x44, 0xd9,
-	0xc0, 0x18, 0xad, 0x7b, 0xe8, 0xaf, 0xed, 0x7a, 0x8f, 0x0c, 0x5a, 0x42, 0x73, 0xdf, 0xa3, 0xad,
-	0x42, 0x9c, 0xc6, 0xb8, 0x48, 0x41, 0xf5, 0x3c, 0xa0, 0x0f, 0xda, 0x7b, 0x22, 0x34, 0x57, 0x1d,
-	0xb5, 0xff, 0x3a, 0xcc, 0x8a, 0x63, 0x44, 0xad, 0xa2, 0x4d, 0xcd, 0x3f, 0x83, 0xf5, 0x61, 0x29,
-	0x85, 0x6a, 0xd3, 0xa5, 0x3b, 0xcf, 0xa0, 0x78, 0xba, 0x21, 0x0e, 0xd1, 0xe8, 0x8e, 0xd6, 0xd5,
-	0x5c, 0xec, 0x96, 0x5a, 0x63, 0x77, 0xe1, 0xcf, 0x81, 0x5d, 0x54, 0xa4, 0xbf, 0x5f, 0xd5, 0xed,
-	0xfc, 0x4d, 0x17, 0x8b, 0xf4, 0x8d, 0xfb, 0xc7, 0xed, 0x8e, 0x1b, 0xdf, 0x3b, 0x4e, 0xde, 0x72,
-	0x4e, 0x3e, 0x72, 0x4e, 0x3e, 0x73, 0x4e, 0x36, 0x39, 0x27, 0x5f, 0x39, 0x27, 0xef, 0x7b, 0x6e,
-	0x6c, 0xf6, 0xdc, 0xd8, 0xee, 0xb9, 0xf1, 0x7c, 0x15, 0xbc, 0xc8, 0x30, 0xf3, 0x86, 0x53, 0x8c,
-	0x9c, 0x15, 0xb8, 0x4b, 0x58, 0x61, 0x32, 0x4b, 0x9d, 0x29, 0x46, 0x11, 0x2e, 0x8e, 0xa7, 0xf4,
-	0x2c, 0x7d, 0xab, 0x9b, 0x9f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xd8, 0xb5, 0xfe, 0x5d, 0xe6, 0x01,
-	0x00, 0x00,
+	// 601 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcb, 0x6e, 0xd3, 0x40,
+	0x14, 0xcd, 0xd4, 0x79, 0xde, 0xa4, 0x52, 0x99, 0x3e, 0xb0, 0xba, 0x70, 0x43, 0x10, 0x92, 0xc5,
+	0xa3, 0x41, 0x61, 0xc5, 0xb2, 0xad, 0x90, 0xba, 0xa8, 0xa0, 0x4c, 0xb2, 0x62, 0x63, 0x4d, 0xec,
+	0x4b, 0x62, 0x35, 0xf6, 0x98, 0x99, 0x49, 0xaa, 0xee, 0xf8, 0x04, 0x3e, 0x83, 0x1f, 0xe0, 0x17,
+	0x10, 0xcb, 0x4a, 0x6c, 0xba, 0x42, 0x34, 0xfd, 0x01, 0x3e, 0x01, 0xcd, 0x38, 0x69, 0x0c, 0x12,
+	0x02, 0x76, 0xe7, 0x9e, 0x33, 0x33, 0x39, 0xf7, 0xdc, 0x1b, 0xc3, 0xdd, 0xb1, 0xd6, 0xd9, 0x48,
+	0x66, 0x61, 0x77, 0x09, 0xf6, 0x33, 0x29, 0xb4, 0xa0, 0xf5, 0x65, 0xbd, 0xbb, 0x35, 0x12, 0x23,
+	0x61, 0xc9, 0xae, 0x41, 0xb9, 0xde, 0xf9, 0xba, 0x06, 0xcd, 0xe3, 0xc1, 0xe0, 0x94, 0xe1, 0xbb,
+	0x29, 0x2a, 0x4d, 0x77, 0xa0, 0x9a, 0xa0, 0x1e, 0x8b, 0xc8, 0x25, 0x6d, 0xe2, 0x37, 0xd8, 0xa2,
+	0xa2, 0x1b, 0xe0, 0x4c, 0xe5, 0xc4, 0x5d, 0xb3, 0xa4, 0x81, 0xf4, 0x21, 0xd4, 0xc6, 0xc8, 0x23,
+	0x94, 0xca, 0x75, 0xda, 0x8e, 0xdf, 0xec, 0x6d, 0xec, 0xdf, 0xfe, 0xf6, 0xb1, 0x15, 0xd8, 0xf2,
+	0x00, 0xa5, 0x50, 0x1e, 0x8a, 0xe8, 0xc2, 0x2d, 0xb7, 0x89, 0xdf, 0x62, 0x16, 0xd3, 0x3d, 0x68,
+	0x4a, 0x4c, 0x84, 0xc6, 0x80, 0x47, 0x91, 0x74, 0x2b, 0xf6, 0x65, 0xc8, 0xa9, 0x83, 0x28, 0x92,
+	0xe6, 0xd2, 0x58, 0x28, 0xed, 0x56, 0xad, 0x62, 0x31, 0xdd, 0x82, 0x8a, 0xf5, 0xed, 0xd6, 0x2c,
+	0x99, 0x17, 0xf4, 0x31, 0xd4, 0xb5, 0xe4, 0xf1, 0xc4, 0x78, 0xa9, 0xff, 0xc1, 0xcb, 0xed, 0x09,
+	0xfa, 0x04, 0x1c, 0x3d, 0x51, 0x6e, 0xa3, 0x4d, 0xfc, 0x66, 0x8f, 0xae, 0x0e, 0x0e, 0x4e, 0xfa,
+	0x7d, 0xcd, 0x35, 0x1e, 0xd6, 0xe6, 0xdf, 0xf6, 0x9c, 0xc1, 0x49, 0x9f, 0x99, 0x73, 0xb4, 0x07,
+	0xdb, 0x3c, 0x0c, 0x31, 0xd3, 0x81, 0xb1, 0x1d, 0x60, 0x1a, 0x8a, 0x28, 0x4e, 0x47, 0xca, 0x85,
+	0xb6, 0xe3, 0x37, 0xd8, 0x66, 0x2e, 0x1e, 0x8a, 0xe8, 0xe2, 0xc5, 0x52, 0xea, 0x7c, 0x22, 0xd0,
+	0xca, 0x53, 0x55, 0x99, 0x48, 0x15, 0x9a, 0x5e, 0x8e, 0x44, 0x84, 0x36, 0xd4, 0x0a, 0xb3, 0xb8,
+	0x18, 0xe0, 0xda, 0xbf, 0x06, 0xe8, 0x14, 0x02, 0x2c, 0x76, 0x5d, 0xfe, 0x6b, 0xd7, 0xf7, 0x61,
+	0xfd, 0x17, 0xff, 0x8b, 0xc0, 0x5b, 0xc3, 0x82, 0xf1, 0x4e, 0x0f, 0xaa, 0xf9, 0x45, 0x33, 0xef,
+	0x33, 0xbc, 0x58, 0x2c, 0x81, 0x81, 0x66, 0x33, 0x66, 0x7c, 0x32, 0xc5, 0xdc, 0x6d, 0x83, 0x2d,
+	0xaa, 0xce, 0x67, 0x02, 0xf5, 0x65, 0x74, 0xd4, 0x85, 0xda, 0x0c, 0xa5, 0x8a, 0x45, 0x6a, 0xaf,
+	0xae, 0xb3, 0x65, 0x49, 0xef, 0x41, 0x2b, 0x8c, 0xb3, 0x31, 0xca, 0x40, 0x4d, 0x63, 0x8d, 0x76,
+	0x93, 0xd6, 0x59, 0x33, 0xe7, 0xfa, 0x86, 0x32, 0x1b, 0xa1, 0x50, 0xce, 0x50, 0x06, 0x29, 0x4f,
+	0xd0, 0xf6, 0xda, 0x60, 0x90, 0x53, 0x2f, 0x79, 0x82, 0xb4, 0x0b, 0x9b, 0x29, 0x8e, 0x84, 0x8e,
+	0xb9, 0xc6, 0x28, 0xb0, 0xb3, 0x0f, 0xc5, 0xc4, 0x6e, 0x55, 0x83, 0xd1, 0x95, 0x74, 0xba, 0x50,
+	0xe8, 0x23, 0xb8, 0x93, 0x21, 0xca, 0x20, 0x44, 0xa9, 0xe3, 0xb7, 0x71, 0xc8, 0x35, 0x2a, 0xb7,
+	0xd2, 0x76, 0xfc, 0x16, 0xdb, 0x30, 0xc2, 0x51, 0x81, 0xef, 0x1d, 0x40, 0xd9, 0xcc, 0x8c, 0x3e,
+	0x87, 0xea, 0x31, 0x4f, 0xa3, 0x09, 0xd2, 0xed, 0x42, 0x9e, 0xab, 0xff, 0xc8, 0xee, 0xce, 0xef,
+	0x74, 0x3e, 0xe4, 0x4e, 0xa9, 0xf7, 0x1a, 0xc0, 0x30, 0x7d, 0x2d, 0x91, 0x27, 0xf4, 0x08, 0x5a,
+	0xf9, 0x43, 0x8b, 0xfa, 0x7f, 0x9f, 0xf3, 0xc9, 0x53, 0x72, 0xf8, 0xea, 0xea, 0xda, 0x2b, 0xfd,
+	0xb8, 0xf6, 0xc8, 0xfb, 0xb9, 0x47, 0x3e, 0xce, 0x3d, 0xf2, 0x65, 0xee, 0x91, 0xcb, 0xb9, 0x47,
+	0xbe, 0xcf, 0x3d, 0xf2, 0xe1, 0xc6, 0x2b, 0x5d, 0xde, 0x78, 0xa5, 0xab, 0x1b, 0xaf, 0xf4, 0xe6,
+	0xc1, 0x28, 0xd6, 0xe3, 0xe9, 0x70, 0x3f, 0x14, 0x49, 0xf7, 0x1c, 0xf9, 0x0c, 0xcf, 0x85, 0x3c,
+	0x53, 0xdd, 0x50, 0x24, 0x89, 0x48, 0x6f, 0xbf, 0x0b, 0xc3, 0xaa, 0xcd, 0xed, 0xd9, 0xcf, 0x01,
+	0x00, 0x35, 0x82, 0x1e, 0x89, 0x33, 0x04, 0x00, 0x00,
 }
 
 func (this *HTTPRequest) Equal(that interface{}) bool {
@@ -276,6 +437,34 @@ func (this *HTTPRequest) Equal(that interface{}) bool {
 	if !bytes.Equal(this.Body, that1.Body) {
 		return false
 	}
+	if this.RemoteAddr != that1.RemoteAddr {
+		return false
+	}
+	if this.Host != that1.Host {
+		return false
+	}
+	if this.Proto != that1.Proto {
+		return false
+	}
+	if len(this.Trailers) != len(that1.Trailers) {
+		return false
+	}
+	for i := range this.Trailers {
+		if !this.Trailers[i].Equal(that1.Trailers[i]) {
+			return false
+		}
+	}
+	if !this.TLS.Equal(that1.TLS) {
+		return false
+	}
+	if len(this.AcceptBodyEncodings) != len(that1.AcceptBodyEncodings) {
+		return false
+	}
+	for i := range this.AcceptBodyEncodings {
+		if this.AcceptBodyEncodings[i] != that1.AcceptBodyEncodings[i] {
+			return false
+		}
+	}
 	return true
 }
 func (this *HTTPResponse) Equal(that interface{}) bool {
@@ -311,6 +500,17 @@ func (this *HTTPResponse) Equal(that interface{}) bool {
 	if !bytes.Equal(this.Body, that1.Body) {
 		return false
 	}
+	if len(this.Trailers) != len(that1.Trailers) {
+		return false
+	}
+	for i := range this.Trailers {
+		if !this.Trailers[i].Equal(that1.Trailers[i]) {
+			return false
+		}
+	}
+	if this.BodyEncoding != that1.BodyEncoding {
+		return false
+	}
 	return true
 }
 func (this *Header) Equal(that interface{}) bool {
@@ -345,11 +545,52 @@ func (this *Header) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *TLSState) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*TLSState)
+	if !ok {
+		that2, ok := that.(TLSState)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Version != that1.Version {
+		return false
+	}
+	if this.CipherSuite != that1.CipherSuite {
+		return false
+	}
+	if this.ServerName != that1.ServerName {
+		return false
+	}
+	if this.NegotiatedProtocol != that1.NegotiatedProtocol {
+		return false
+	}
+	if len(this.PeerCertificates) != len(that1.PeerCertificates) {
+		return false
+	}
+	for i := range this.PeerCertificates {
+		if !bytes.Equal(this.PeerCertificates[i], that1.PeerCertificates[i]) {
+			return false
+		}
+	}
+	return true
+}
 func (this *HTTPRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 14)
 	s = append(s, "&httpgrpc.HTTPRequest{")
 	s = append(s, "Method: "+fmt.Sprintf("%#v", this.Method)+",\n")
 	s = append(s, "Url: "+fmt.Sprintf("%#v", this.Url)+",\n")
@@ -357,6 +598,16 @@ func (this *HTTPRequest) GoString() string {
 		s = append(s, "Headers: "+fmt.Sprintf("%#v", this.Headers)+",\n")
 	}
 	s = append(s, "Body: "+fmt.Sprintf("%#v", this.Body)+",\n")
+	s = append(s, "RemoteAddr: "+fmt.Sprintf("%#v", this.RemoteAddr)+",\n")
+	s = append(s, "Host: "+fmt.Sprintf("%#v", this.Host)+",\n")
+	s = append(s, "Proto: "+fmt.Sprintf("%#v", this.Proto)+",\n")
+	if this.Trailers != nil {
+		s = append(s, "Trailers: "+fmt.Sprintf("%#v", this.Trailers)+",\n")
+	}
+	if this.TLS != nil {
+		s = append(s, "TLS: "+fmt.Sprintf("%#v", this.TLS)+",\n")
+	}
+	s = append(s, "AcceptBodyEncodings: "+fmt.Sprintf("%#v", this.AcceptBodyEncodings)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -364,13 +615,17 @@ func (this *HTTPResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 9)
 	s = append(s, "&httpgrpc.HTTPResponse{")
 	s = append(s, "Code: "+fmt.Sprintf("%#v", this.Code)+",\n")
 	if this.Headers != nil {
 		s = append(s, "Headers: "+fmt.Sprintf("%#v", this.Headers)+",\n")
 	}
 	s = append(s, "Body: "+fmt.Sprintf("%#v", this.Body)+",\n")
+	if this.Trailers != nil {
+		s = append(s, "Trailers: "+fmt.Sprintf("%#v", this.Trailers)+",\n")
+	}
+	s = append(s, "BodyEncoding: "+fmt.Sprintf("%#v", this.BodyEncoding)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -385,6 +640,20 @@ func (this *Header) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *TLSState) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 9)
+	s = append(s, "&httpgrpc.TLSState{")
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	s = append(s, "CipherSuite: "+fmt.Sprintf("%#v", this.CipherSuite)+",\n")
+	s = append(s, "ServerName: "+fmt.Sprintf("%#v", this.ServerName)+",\n")
+	s = append(s, "NegotiatedProtocol: "+fmt.Sprintf("%#v", this.NegotiatedProtocol)+",\n")
+	s = append(s, "PeerCertificates: "+fmt.Sprintf("%#v", this.PeerCertificates)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringHttpgrpc(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -474,6 +743,116 @@ var _HTTP_serviceDesc = grpc.ServiceDesc{
 	Metadata: "httpgrpc/httpgrpc.proto",
 }
 
+// HTTPStreamClient is the client API for HTTPStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type HTTPStreamClient interface {
+	// HandleStream is Handle with the bodies streamed in both directions. The
+	// first message each way carries the method, URL and headers, or the code and
+	// headers, and optionally the start of the body; the rest only carry body.
+	HandleStream(ctx context.Context, opts ...grpc.CallOption) (HTTPStream_HandleStreamClient, error)
+}
+
+type hTTPStreamClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewHTTPStreamClient(cc *grpc.ClientConn) HTTPStreamClient {
+	return &hTTPStreamClient{cc}
+}
+
+func (c *hTTPStreamClient) HandleStream(ctx context.Context, opts ...grpc.CallOption) (HTTPStream_HandleStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_HTTPStream_serviceDesc.Streams[0], "/httpgrpc.HTTPStream/HandleStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hTTPStreamHandleStreamClient{stream}
+	return x, nil
+}
+
+type HTTPStream_HandleStreamClient interface {
+	Send(*HTTPRequest) error
+	Recv() (*HTTPResponse, error)
+	grpc.ClientStream
+}
+
+type hTTPStreamHandleStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *hTTPStreamHandleStreamClient) Send(m *HTTPRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hTTPStreamHandleStreamClient) Recv() (*HTTPResponse, error) {
+	m := new(HTTPResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HTTPStreamServer is the server API for HTTPStream service.
+type HTTPStreamServer interface {
+	// HandleStream is Handle with the bodies streamed in both directions. The
+	// first message each way carries the method, URL and headers, or the code and
+	// headers, and optionally the start of the body; the rest only carry body.
+	HandleStream(HTTPStream_HandleStreamServer) error
+}
+
+// UnimplementedHTTPStreamServer can be embedded to have forward compatible implementations.
+type UnimplementedHTTPStreamServer struct {
+}
+
+func (*UnimplementedHTTPStreamServer) HandleStream(srv HTTPStream_HandleStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method HandleStream not implemented")
+}
+
+func RegisterHTTPStreamServer(s *grpc.Server, srv HTTPStreamServer) {
+	s.RegisterService(&_HTTPStream_serviceDesc, srv)
+}
+
+func _HTTPStream_HandleStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HTTPStreamServer).HandleStream(&hTTPStreamHandleStreamServer{stream})
+}
+
+type HTTPStream_HandleStreamServer interface {
+	Send(*HTTPResponse) error
+	Recv() (*HTTPRequest, error)
+	grpc.ServerStream
+}
+
+type hTTPStreamHandleStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *hTTPStreamHandleStreamServer) Send(m *HTTPResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hTTPStreamHandleStreamServer) Recv() (*HTTPRequest, error) {
+	m := new(HTTPRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _HTTPStream_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "httpgrpc.HTTPStream",
+	HandlerType: (*HTTPStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "HandleStream",
+			Handler:       _HTTPStream_HandleStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "httpgrpc/httpgrpc.proto",
+}
+
 func (m *HTTPRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -494,6 +873,62 @@ func (m *HTTPRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.AcceptBodyEncodings) > 0 {
+		for iNdEx := len(m.AcceptBodyEncodings) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.AcceptBodyEncodings[iNdEx])
+			copy(dAtA[i:], m.AcceptBodyEncodings[iNdEx])
+			i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.AcceptBodyEncodings[iNdEx])))
+			i--
+			dAtA[i] = 0x52
+		}
+	}
+	if m.TLS != nil {
+		{
+			size, err := m.TLS.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintHttpgrpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x4a
+	}
+	if len(m.Trailers) > 0 {
+		for iNdEx := len(m.Trailers) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Trailers[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintHttpgrpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x42
+		}
+	}
+	if len(m.Proto) > 0 {
+		i -= len(m.Proto)
+		copy(dAtA[i:], m.Proto)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.Proto)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Host) > 0 {
+		i -= len(m.Host)
+		copy(dAtA[i:], m.Host)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.Host)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.RemoteAddr) > 0 {
+		i -= len(m.RemoteAddr)
+		copy(dAtA[i:], m.RemoteAddr)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.RemoteAddr)))
+		i--
+		dAtA[i] = 0x2a
+	}
 	if len(m.Body) > 0 {
 		i -= len(m.Body)
 		copy(dAtA[i:], m.Body)
@@ -552,6 +987,27 @@ func (m *HTTPResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.BodyEncoding) > 0 {
+		i -= len(m.BodyEncoding)
+		copy(dAtA[i:], m.BodyEncoding)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.BodyEncoding)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Trailers) > 0 {
+		for iNdEx := len(m.Trailers) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Trailers[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintHttpgrpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
 	if len(m.Body) > 0 {
 		i -= len(m.Body)
 		copy(dAtA[i:], m.Body)
@@ -620,20 +1076,76 @@ func (m *Header) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintHttpgrpc(dAtA []byte, offset int, v uint64) int {
-	offset -= sovHttpgrpc(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *TLSState) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *HTTPRequest) Size() (n int) {
-	if m == nil {
-		return 0
+
+func (m *TLSState) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TLSState) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.PeerCertificates) > 0 {
+		for iNdEx := len(m.PeerCertificates) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.PeerCertificates[iNdEx])
+			copy(dAtA[i:], m.PeerCertificates[iNdEx])
+			i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.PeerCertificates[iNdEx])))
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	if len(m.NegotiatedProtocol) > 0 {
+		i -= len(m.NegotiatedProtocol)
+		copy(dAtA[i:], m.NegotiatedProtocol)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.NegotiatedProtocol)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.ServerName) > 0 {
+		i -= len(m.ServerName)
+		copy(dAtA[i:], m.ServerName)
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(len(m.ServerName)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.CipherSuite != 0 {
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(m.CipherSuite))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Version != 0 {
+		i = encodeVarintHttpgrpc(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintHttpgrpc(dAtA []byte, offset int, v uint64) int {
+	offset -= sovHttpgrpc(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *HTTPRequest) Size() (n int) {
+	if m == nil {
+		return 0
 	}
 	var l int
 	_ = l
@@ -655,6 +1167,34 @@ func (m *HTTPRequest) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHttpgrpc(uint64(l))
 	}
+	l = len(m.RemoteAddr)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	l = len(m.Host)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	l = len(m.Proto)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	if len(m.Trailers) > 0 {
+		for _, e := range m.Trailers {
+			l = e.Size()
+			n += 1 + l + sovHttpgrpc(uint64(l))
+		}
+	}
+	if m.TLS != nil {
+		l = m.TLS.Size()
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	if len(m.AcceptBodyEncodings) > 0 {
+		for _, s := range m.AcceptBodyEncodings {
+			l = len(s)
+			n += 1 + l + sovHttpgrpc(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -677,6 +1217,16 @@ func (m *HTTPResponse) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHttpgrpc(uint64(l))
 	}
+	if len(m.Trailers) > 0 {
+		for _, e := range m.Trailers {
+			l = e.Size()
+			n += 1 + l + sovHttpgrpc(uint64(l))
+		}
+	}
+	l = len(m.BodyEncoding)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
 	return n
 }
 
@@ -699,6 +1249,35 @@ func (m *Header) Size() (n int) {
 	return n
 }
 
+func (m *TLSState) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Version != 0 {
+		n += 1 + sovHttpgrpc(uint64(m.Version))
+	}
+	if m.CipherSuite != 0 {
+		n += 1 + sovHttpgrpc(uint64(m.CipherSuite))
+	}
+	l = len(m.ServerName)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	l = len(m.NegotiatedProtocol)
+	if l > 0 {
+		n += 1 + l + sovHttpgrpc(uint64(l))
+	}
+	if len(m.PeerCertificates) > 0 {
+		for _, b := range m.PeerCertificates {
+			l = len(b)
+			n += 1 + l + sovHttpgrpc(uint64(l))
+		}
+	}
+	return n
+}
+
 func sovHttpgrpc(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -714,11 +1293,22 @@ func (this *HTTPRequest) String() string {
 		repeatedStringForHeaders += strings.Replace(f.String(), "Header", "Header", 1) + ","
 	}
 	repeatedStringForHeaders += "}"
+	repeatedStringForTrailers := "[]*Header{"
+	for _, f := range this.Trailers {
+		repeatedStringForTrailers += strings.Replace(f.String(), "Header", "Header", 1) + ","
+	}
+	repeatedStringForTrailers += "}"
 	s := strings.Join([]string{`&HTTPRequest{`,
 		`Method:` + fmt.Sprintf("%v", this.Method) + `,`,
 		`Url:` + fmt.Sprintf("%v", this.Url) + `,`,
 		`Headers:` + repeatedStringForHeaders + `,`,
 		`Body:` + fmt.Sprintf("%v", this.Body) + `,`,
+		`RemoteAddr:` + fmt.Sprintf("%v", this.RemoteAddr) + `,`,
+		`Host:` + fmt.Sprintf("%v", this.Host) + `,`,
+		`Proto:` + fmt.Sprintf("%v", this.Proto) + `,`,
+		`Trailers:` + repeatedStringForTrailers + `,`,
+		`TLS:` + strings.Replace(this.TLS.String(), "TLSState", "TLSState", 1) + `,`,
+		`AcceptBodyEncodings:` + fmt.Sprintf("%v", this.AcceptBodyEncodings) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -732,10 +1322,17 @@ func (this *HTTPResponse) String() string {
 		repeatedStringForHeaders += strings.Replace(f.String(), "Header", "Header", 1) + ","
 	}
 	repeatedStringForHeaders += "}"
+	repeatedStringForTrailers := "[]*Header{"
+	for _, f := range this.Trailers {
+		repeatedStringForTrailers += strings.Replace(f.String(), "Header", "Header", 1) + ","
+	}
+	repeatedStringForTrailers += "}"
 	s := strings.Join([]string{`&HTTPResponse{`,
 		`Code:` + fmt.Sprintf("%v", this.Code) + `,`,
 		`Headers:` + repeatedStringForHeaders + `,`,
 		`Body:` + fmt.Sprintf("%v", this.Body) + `,`,
+		`Trailers:` + repeatedStringForTrailers + `,`,
+		`BodyEncoding:` + fmt.Sprintf("%v", this.BodyEncoding) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -751,6 +1348,20 @@ func (this *Header) String() string {
 	}, "")
 	return s
 }
+func (this *TLSState) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&TLSState{`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`CipherSuite:` + fmt.Sprintf("%v", this.CipherSuite) + `,`,
+		`ServerName:` + fmt.Sprintf("%v", this.ServerName) + `,`,
+		`NegotiatedProtocol:` + fmt.Sprintf("%v", this.NegotiatedProtocol) + `,`,
+		`PeerCertificates:` + fmt.Sprintf("%v", this.PeerCertificates) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringHttpgrpc(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -920,16 +1531,211 @@ func (m *HTTPRequest) Unmarshal(dAtA []byte) error {
 				m.Body = []byte{}
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemoteAddr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RemoteAddr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Host", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Host = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Proto", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Proto = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Trailers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Trailers = append(m.Trailers, &Header{})
+			if err := m.Trailers[len(m.Trailers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TLS", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TLS == nil {
+				m.TLS = &TLSState{}
+			}
+			if err := m.TLS.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AcceptBodyEncodings", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AcceptBodyEncodings = append(m.AcceptBodyEncodings, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHttpgrpc(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthHttpgrpc
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthHttpgrpc
 			}
 			if (iNdEx + skippy) > l {
@@ -1060,16 +1866,79 @@ func (m *HTTPResponse) Unmarshal(dAtA []byte) error {
 				m.Body = []byte{}
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Trailers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Trailers = append(m.Trailers, &Header{})
+			if err := m.Trailers[len(m.Trailers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BodyEncoding", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BodyEncoding = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHttpgrpc(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthHttpgrpc
-			}
-			if (iNdEx + skippy) < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthHttpgrpc
 			}
 			if (iNdEx + skippy) > l {
@@ -1183,10 +2052,191 @@ func (m *Header) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthHttpgrpc
 			}
-			if (iNdEx + skippy) < 0 {
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TLSState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHttpgrpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TLSState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TLSState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CipherSuite", wireType)
+			}
+			m.CipherSuite = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CipherSuite |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServerName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServerName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NegotiatedProtocol", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NegotiatedProtocol = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerCertificates", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHttpgrpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHttpgrpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PeerCertificates = append(m.PeerCertificates, make([]byte, postIndex-iNdEx))
+			copy(m.PeerCertificates[len(m.PeerCertificates)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHttpgrpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthHttpgrpc
 			}
 			if (iNdEx + skippy) > l {
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sercand/kuberesolver/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/instrument"
+	"github.com/weaveworks/common/middleware"
+)
+
+// ClientTLSConfig configures TLS for a Client. If CertPath and KeyPath are
+// set, the client authenticates itself to the server with them.
+type ClientTLSConfig struct {
+	CertPath           string `yaml:"tls_cert_path"`
+	KeyPath            string `yaml:"tls_key_path"`
+	CAPath             string `yaml:"tls_ca_path"`
+	ServerName         string `yaml:"tls_server_name"`
+	InsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify"`
+}
+
+// ClientConfig configures a Client made by NewClientWithConfig.
+type ClientConfig struct {
+	TLSEnabled bool            `yaml:"tls_enabled"`
+	TLS        ClientTLSConfig `yaml:",inline"`
+
+	MaxRecvMsgSize int `yaml:"max_recv_msg_size"`
+	MaxSendMsgSize int `yaml:"max_send_msg_size"`
+
+	KeepaliveTime                time.Duration `yaml:"keepalive_time"`
+	KeepaliveTimeout             time.Duration `yaml:"keepalive_timeout"`
+	KeepalivePermitWithoutStream bool          `yaml:"keepalive_permit_without_stream"`
+
+	LoadBalancingPolicy string `yaml:"load_balancing_policy"`
+
+	BackoffBaseDelay  time.Duration `yaml:"backoff_base_delay"`
+	BackoffMaxDelay   time.Duration `yaml:"backoff_max_delay"`
+	MinConnectTimeout time.Duration `yaml:"min_connect_timeout"`
+
+	DNSRefreshInterval time.Duration `yaml:"dns_refresh_interval"`
+
+	// Addresses without a scheme are treated as kubernetes:// ones, so they
+	// won't resolve if this is set.
+	DisableKubernetesResolver bool `yaml:"disable_kubernetes_resolver"`
+
+	// Comma-separated encodings the server may compress response bodies
+	// with, in order of preference; see RegisterCompressor.
+	Compression string `yaml:"compression"`
+
+	// Requests which can't be retried or hedged have their bodies streamed
+	// over HandleStream, unless this is set.
+	DisableStreaming bool `yaml:"disable_streaming"`
+	// If set, the connection to the client is aborted when a streamed response
+	// fails part way through, by panicking with http.ErrAbortHandler, which
+	// middleware that recovers panics must let through. Otherwise the response
+	// just ends early.
+	AbortIncompleteResponses bool `yaml:"abort_incomplete_responses"`
+
+	// Retries and hedging only apply to requests with idempotent methods,
+	// which are buffered rather than streamed so they can be sent again.
+	MaxRetries           int           `yaml:"max_retries"`
+	RetryMinBackoff      time.Duration `yaml:"retry_min_backoff"`
+	RetryMaxBackoff      time.Duration `yaml:"retry_max_backoff"`
+	HedgeAfterPercentile float64       `yaml:"hedge_after_percentile"`
+	RetryBudgetRatio     float64       `yaml:"retry_budget_ratio"`
+
+	// AttemptCollector observes each attempt at a request, including retries
+	// and hedged requests. If nil, they are observed in the
+	// httpgrpc_client_attempt_duration_seconds histogram, registered with the
+	// default Prometheus registerer.
+	AttemptCollector instrument.Collector `yaml:"-"`
+
+	UnaryInterceptors  []grpc.UnaryClientInterceptor  `yaml:"-"`
+	StreamInterceptors []grpc.StreamClientInterceptor `yaml:"-"`
+	ExtraDialOptions   []grpc.DialOption              `yaml:"-"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *ClientConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("httpgrpc.client", f)
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given
+// FlagSet, with the given prefix, so several clients can be configured.
+func (cfg *ClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.TLSEnabled, prefix+".tls-enabled", false, "Connect to the server using TLS.")
+	f.StringVar(&cfg.TLS.CertPath, prefix+".tls-cert-path", "", "Client certificate to authenticate to the server with.")
+	f.StringVar(&cfg.TLS.KeyPath, prefix+".tls-key-path", "", "Key for the client certificate.")
+	f.StringVar(&cfg.TLS.CAPath, prefix+".tls-ca-path", "", "CA certificates to verify the server with. If blank, the system CAs are used.")
+	f.StringVar(&cfg.TLS.ServerName, prefix+".tls-server-name", "", "Override the server name expected in the server certificate.")
+	f.BoolVar(&cfg.TLS.InsecureSkipVerify, prefix+".tls-insecure-skip-verify", false, "Skip verifying the server certificate.")
+	f.IntVar(&cfg.MaxRecvMsgSize, prefix+".grpc-max-recv-msg-size", 0, "Limit on the size of a gRPC message this client can receive (bytes), 0 for the gRPC default. Also limits the size of buffered response bodies once decompressed.")
+	f.IntVar(&cfg.MaxSendMsgSize, prefix+".grpc-max-send-msg-size", 0, "Limit on the size of a gRPC message this client can send (bytes), 0 for the gRPC default.")
+	f.DurationVar(&cfg.KeepaliveTime, prefix+".keepalive-time", 0, "How often to ping the server when there is no activity, 0 to disable.")
+	f.DurationVar(&cfg.KeepaliveTimeout, prefix+".keepalive-timeout", 20*time.Second, "How long to wait for a keepalive ping to be acknowledged before closing the connection.")
+	f.BoolVar(&cfg.KeepalivePermitWithoutStream, prefix+".keepalive-permit-without-stream", false, "Send keepalive pings even when there are no active requests.")
+	f.StringVar(&cfg.LoadBalancingPolicy, prefix+".load-balancing-policy", "round_robin", "gRPC load balancing policy, e.g. round_robin or pick_first.")
+	f.DurationVar(&cfg.BackoffBaseDelay, prefix+".backoff-base-delay", backoff.DefaultConfig.BaseDelay, "How long to wait before reconnecting after the first failure.")
+	f.DurationVar(&cfg.BackoffMaxDelay, prefix+".backoff-max-delay", backoff.DefaultConfig.MaxDelay, "Upper bound on how long to wait between reconnection attempts.")
+	f.DurationVar(&cfg.MinConnectTimeout, prefix+".min-connect-timeout", 20*time.Second, "Minimum time to allow a connection attempt to complete.")
+	f.DurationVar(&cfg.DNSRefreshInterval, prefix+".dns-refresh-interval", 30*time.Second, "How often to re-resolve dns:// and dns+srv:// addresses, 0 to only re-resolve on connection failures.")
+	f.BoolVar(&cfg.DisableKubernetesResolver, prefix+".disable-kubernetes-resolver", false, "Don't register the kubernetes:// resolver, for clients running outside Kubernetes.")
+	f.StringVar(&cfg.Compression, prefix+".compression", "", "Comma-separated list of encodings the server may compress response bodies with, in order of preference: gzip, snappy, zstd, or others registered with RegisterCompressor. Empty to disable.")
+	f.BoolVar(&cfg.DisableStreaming, prefix+".disable-streaming", false, "Buffer request and response bodies rather than streaming them, as servers from before streaming was supported require.")
+	f.BoolVar(&cfg.AbortIncompleteResponses, prefix+".abort-incomplete-responses", false, "Abort the connection to the client when a streamed response body fails part way through, rather than ending the response early. Handlers wrapping the client must let the http.ErrAbortHandler panic through.")
+	f.IntVar(&cfg.MaxRetries, prefix+".max-retries", 0, "How many times to retry requests with idempotent methods which fail with a 5xx status or because the server is unavailable, 0 to disable. Such requests are buffered rather than streamed.")
+	f.DurationVar(&cfg.RetryMinBackoff, prefix+".retry-min-backoff", 100*time.Millisecond, "How long to wait before the first retry.")
+	f.DurationVar(&cfg.RetryMaxBackoff, prefix+".retry-max-backoff", time.Second, "Upper bound on how long to wait between retries.")
+	f.Float64Var(&cfg.HedgeAfterPercentile, prefix+".hedge-after-percentile", 0, "Send a second copy of a request with an idempotent method if it takes longer than this percentile of recent latencies, e.g. 0.95, taking whichever response comes first. 0 to disable.")
+	f.Float64Var(&cfg.RetryBudgetRatio, prefix+".retry-budget-ratio", 0.1, "Limit retries and hedged requests to this fraction of requests, to avoid retry storms.")
+}
+
+func (cfg *ClientConfig) transportCredentials() (credentials.TransportCredentials, error) {
+	if !cfg.TLSEnabled {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	}
+	if cfg.TLS.CAPath != "" {
+		caPEM, err := ioutil.ReadFile(cfg.TLS.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLS.CAPath)
+		}
+	}
+	if (cfg.TLS.CertPath == "") != (cfg.TLS.KeyPath == "") {
+		return nil, errors.New("both the client certificate and key must be set")
+	}
+	if cfg.TLS.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertPath, cfg.TLS.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// DialOptions returns the gRPC dial options for this config.
+func (cfg *ClientConfig) DialOptions() ([]grpc.DialOption, error) {
+	if cfg.LoadBalancingPolicy == "" {
+		return nil, errors.New("load balancing policy must be set")
+	}
+	creds, err := cfg.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, cfg.LoadBalancingPolicy)),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithResolvers(resolvers(cfg.DNSRefreshInterval)...),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  cfg.BackoffBaseDelay,
+				Multiplier: backoff.DefaultConfig.Multiplier,
+				Jitter:     backoff.DefaultConfig.Jitter,
+				MaxDelay:   cfg.BackoffMaxDelay,
+			},
+			MinConnectTimeout: cfg.MinConnectTimeout,
+		}),
+		grpc.WithChainUnaryInterceptor(append([]grpc.UnaryClientInterceptor{
+			otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
+			middleware.ClientUserHeaderInterceptor,
+		}, cfg.UnaryInterceptors...)...),
+		grpc.WithChainStreamInterceptor(append([]grpc.StreamClientInterceptor{
+			otgrpc.OpenTracingStreamClientInterceptor(opentracing.GlobalTracer()),
+			middleware.StreamClientUserHeaderInterceptor,
+		}, cfg.StreamInterceptors...)...),
+	}
+	var callOpts []grpc.CallOption
+	if cfg.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if cfg.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: cfg.KeepalivePermitWithoutStream,
+		}))
+	}
+	return append(opts, cfg.ExtraDialOptions...), nil
+}
+
+// NewClientWithConfig makes a new Client for the given address, which is
+// parsed by ParseURL.
+func NewClientWithConfig(address string, cfg ClientConfig) (*Client, error) {
+	if !cfg.DisableKubernetesResolver {
+		kuberesolver.RegisterInCluster()
+	}
+
+	address, err := ParseURL(address)
+	if err != nil {
+		return nil, err
+	}
+	encodings, err := parseEncodings(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HedgeAfterPercentile < 0 || cfg.HedgeAfterPercentile >= 1 {
+		return nil, errors.New("hedge percentile must be at least 0 and less than 1")
+	}
+	if cfg.MaxRetries > 0 && (cfg.RetryMinBackoff <= 0 || cfg.RetryMaxBackoff < cfg.RetryMinBackoff) {
+		return nil, errors.New("retry backoff must be positive, with the maximum at least the minimum")
+	}
+	dialOptions, err := cfg.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		client:          httpgrpc.NewHTTPClient(conn),
+		stream:          httpgrpc.NewHTTPStreamClient(conn),
+		conn:            conn,
+		encodings:       encodings,
+		maxBodySize:     defaultMaxDecompressedSize,
+		maxRetries:      cfg.MaxRetries,
+		minBackoff:      cfg.RetryMinBackoff,
+		maxBackoff:      cfg.RetryMaxBackoff,
+		budget:          newRetryBudget(cfg.RetryBudgetRatio),
+		collector:       cfg.AttemptCollector,
+		abortIncomplete: cfg.AbortIncompleteResponses,
+	}
+	if cfg.DisableStreaming {
+		client.streamUnsupported = 1
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		client.maxBodySize = cfg.MaxRecvMsgSize
+	}
+	if cfg.HedgeAfterPercentile > 0 {
+		client.latencies = newLatencyTracker(cfg.HedgeAfterPercentile)
+	}
+	if client.collector == nil {
+		registerDefaultAttemptCollector.Do(defaultAttemptCollector.Register)
+		client.collector = defaultAttemptCollector
+	}
+	return client, nil
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+const (
+	// compressionMinSize is the smallest response body worth compressing.
+	compressionMinSize = 1024
+
+	// defaultMaxDecompressedSize limits decompressed response bodies when the
+	// client doesn't set MaxRecvMsgSize, like gRPC's limit on messages.
+	defaultMaxDecompressedSize = 4 * 1024 * 1024
+)
+
+// Compressor compresses response bodies sent between Servers and Clients.
+type Compressor interface {
+	// Name is the content coding, as used in Accept-Encoding.
+	Name() string
+	NewWriter(w io.Writer) CompressWriter
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// CompressWriter is a compressing writer. Flush must write out everything
+// written so far, so it can be decompressed without waiting for the rest.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+var (
+	compressorsMtx sync.RWMutex
+	compressors    = map[string]Compressor{}
+)
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+	RegisterCompressor(snappyCompressor{})
+	RegisterCompressor(zstdCompressor{})
+}
+
+// RegisterCompressor makes a Compressor available to Clients and Servers, by
+// its Name. gzip, snappy and zstd are built in; others can be registered by
+// programs which link an implementation. Both ends must have registered a
+// Compressor for it to be used.
+func RegisterCompressor(c Compressor) {
+	compressorsMtx.Lock()
+	defer compressorsMtx.Unlock()
+	compressors[c.Name()] = c
+}
+
+func getCompressor(name string) Compressor {
+	compressorsMtx.RLock()
+	defer compressorsMtx.RUnlock()
+	return compressors[name]
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string                         { return "gzip" }
+func (gzipCompressor) NewWriter(w io.Writer) CompressWriter { return gzip.NewWriter(w) }
+
+func (gzipCompressor) NewReader(r io.Reader) (io.Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// snappyCompressor uses the snappy framing format, so bodies can be streamed.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string                             { return "snappy" }
+func (snappyCompressor) NewWriter(w io.Writer) CompressWriter     { return snappy.NewBufferedWriter(w) }
+func (snappyCompressor) NewReader(r io.Reader) (io.Reader, error) { return snappy.NewReader(r), nil }
+
+// zstdCompressor encodes and decodes synchronously, so readers and writers
+// which aren't closed don't leave goroutines behind.
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string { return "zstd" }
+
+func (zstdCompressor) NewWriter(w io.Writer) CompressWriter {
+	// Only invalid options are errors.
+	zw, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	return zw
+}
+
+func (zstdCompressor) NewReader(r io.Reader) (io.Reader, error) {
+	zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// parseEncodings parses a comma-separated list of registered encodings.
+func parseEncodings(list string) ([]string, error) {
+	var encodings []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if getCompressor(name) == nil {
+			return nil, fmt.Errorf("unknown compression %q", name)
+		}
+		encodings = append(encodings, name)
+	}
+	return encodings, nil
+}
+
+// acceptsEncoding returns true if the Accept-Encoding header in h allows name.
+func acceptsEncoding(h http.Header, name string) bool {
+	for _, v := range h["Accept-Encoding"] {
+		for _, coding := range strings.Split(v, ",") {
+			params := strings.Split(coding, ";")
+			token := strings.TrimSpace(params[0])
+			if !strings.EqualFold(token, name) && token != "*" {
+				continue
+			}
+			accepted := true
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					q, err := strconv.ParseFloat(param[2:], 64)
+					accepted = err == nil && q > 0
+				}
+			}
+			if accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chooseCompressor returns the first of the encodings the client accepts which
+// is registered here, or nil if the response is already encoded.
+func chooseCompressor(accept []string, header http.Header) Compressor {
+	if ce := header.Get("Content-Encoding"); ce != "" && ce != "identity" {
+		return nil
+	}
+	for _, name := range accept {
+		if c := getCompressor(name); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// bodyEncoder compresses a response body, possibly over several messages.
+type bodyEncoder struct {
+	name   string
+	buf    bytes.Buffer
+	w      CompressWriter
+	server *Server
+}
+
+func newBodyEncoder(c Compressor, s *Server) *bodyEncoder {
+	e := &bodyEncoder{name: c.Name(), server: s}
+	e.w = c.NewWriter(&e.buf)
+	return e
+}
+
+// encode compresses p, which is the last of the body if final. The result is
+// only valid until the next call.
+func (e *bodyEncoder) encode(p []byte, final bool) ([]byte, error) {
+	e.buf.Reset()
+	if _, err := e.w.Write(p); err != nil {
+		return nil, err
+	}
+	var err error
+	if final {
+		err = e.w.Close()
+	} else {
+		err = e.w.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.server.uncompressedBytes != nil {
+		e.server.uncompressedBytes.WithLabelValues(e.name).Add(float64(len(p)))
+		e.server.compressedBytes.WithLabelValues(e.name).Add(float64(e.buf.Len()))
+	}
+	return e.buf.Bytes(), nil
+}
+
+// compressResponse compresses the body of resp, if the client accepts it and
+// it's worth it.
+func (s *Server) compressResponse(accept []string, header http.Header, resp *httpgrpc.HTTPResponse) error {
+	c := chooseCompressor(accept, header)
+	if c == nil || len(resp.Body) < compressionMinSize {
+		return nil
+	}
+	body, err := newBodyEncoder(c, s).encode(resp.Body, true)
+	if err != nil {
+		return err
+	}
+	resp.Body = append([]byte(nil), body...)
+	resp.BodyEncoding = c.Name()
+	return nil
+}
+
+// decompressBody decodes a body compressed by the server.
+func decompressBody(encoding string, r io.Reader) (io.Reader, error) {
+	c := getCompressor(encoding)
+	if c == nil {
+		return nil, fmt.Errorf("unknown body encoding %q", encoding)
+	}
+	return c.NewReader(r)
+}
+
+// decompressResponse decodes the body of resp in place. It fails if the body
+// would be bigger than limit, so a small compressed body can't use up memory.
+func decompressResponse(resp *httpgrpc.HTTPResponse, limit int) error {
+	if resp.BodyEncoding == "" {
+		return nil
+	}
+	r, err := decompressBody(resp.BodyEncoding, bytes.NewReader(resp.Body))
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > limit {
+		return fmt.Errorf("decompressed response body is bigger than %d bytes", limit)
+	}
+	resp.Body, resp.BodyEncoding = body, ""
+	return nil
+}
+
+// passThroughEncoding returns true if the original client accepts the encoding
+// the body was compressed with, in which case the compressed body is passed on
+// with a Content-Encoding header set in h.
+func passThroughEncoding(r *http.Request, encoding string, h http.Header) bool {
+	if !acceptsEncoding(r.Header, encoding) {
+		return false
+	}
+	h.Set("Content-Encoding", encoding)
+	h.Del("Content-Length")
+	h.Add("Vary", "Accept-Encoding")
+	return true
+}
+
+// WithCompressionMetrics makes the Server count the bytes of response bodies
+// before and after compression, by encoding.
+func WithCompressionMetrics(uncompressed, compressed *prometheus.CounterVec) ServerOption {
+	return func(s *Server) {
+		s.uncompressedBytes = uncompressed
+		s.compressedBytes = compressed
+	}
+}
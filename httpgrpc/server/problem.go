@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+// problemContentType is the media type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details object, with extension members for
+// the error details httpgrpc knows about.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	Reason   string            `json:"reason,omitempty"`
+	Domain   string            `json:"domain,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// In seconds, as in the Retry-After header.
+	RetryAfter      float64          `json:"retry-after,omitempty"`
+	InvalidParams   []invalidParam   `json:"invalid-params,omitempty"`
+	QuotaViolations []quotaViolation `json:"quota-violations,omitempty"`
+}
+
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type quotaViolation struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+// writeProblem writes resp as problem details, or returns false if there
+// are no details to render, leaving the response untouched.
+func writeProblem(w http.ResponseWriter, resp *httpgrpc.HTTPResponse, details []proto.Message) bool {
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(int(resp.Code)),
+		Status: int(resp.Code),
+		Detail: string(resp.Body),
+	}
+	rendered := false
+	for _, detail := range details {
+		switch d := detail.(type) {
+		case *rpc.ErrorInfo:
+			p.Reason, p.Domain, p.Metadata = d.Reason, d.Domain, d.Metadata
+		case *rpc.RetryInfo:
+			delay, err := types.DurationFromProto(d.RetryDelay)
+			if err != nil {
+				continue
+			}
+			p.RetryAfter = delay.Seconds()
+		case *rpc.BadRequest:
+			for _, v := range d.FieldViolations {
+				p.InvalidParams = append(p.InvalidParams, invalidParam{Name: v.Field, Reason: v.Description})
+			}
+		case *rpc.QuotaFailure:
+			for _, v := range d.Violations {
+				p.QuotaViolations = append(p.QuotaViolations, quotaViolation{Subject: v.Subject, Description: v.Description})
+			}
+		default:
+			continue
+		}
+		rendered = true
+	}
+	if !rendered {
+		return false
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return false
+	}
+	toHeader(resp.Headers, w.Header())
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Del("Content-Length")
+	if p.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(p.RetryAfter))))
+	}
+	w.WriteHeader(int(resp.Code))
+	w.Write(body)
+	writeTrailers(w, resp.Trailers)
+	return true
+}
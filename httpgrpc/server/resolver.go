@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// Schemes of the resolvers used by Clients. ParseURL maps dns://, dns+srv://
+// and static:// URLs to these.
+const (
+	dnsScheme    = "dns+poll"
+	dnsSRVScheme = "dns+srv"
+	staticScheme = "static"
+)
+
+// gRPC asks for names to be re-resolved whenever a connection fails. As its
+// own DNS resolver does, don't do so more often than this.
+const minResolveInterval = 30 * time.Second
+
+// resolvers returns the resolver builders for a Client; DNS names are
+// re-resolved every refresh.
+func resolvers(refresh time.Duration) []resolver.Builder {
+	return []resolver.Builder{
+		&lookupBuilder{scheme: dnsScheme, refresh: refresh, minInterval: minResolveInterval, lookup: lookupHost},
+		&lookupBuilder{scheme: dnsSRVScheme, refresh: refresh, minInterval: minResolveInterval, lookup: lookupSRV},
+		staticBuilder{},
+	}
+}
+
+func lookupHost(ctx context.Context, endpoint string) ([]resolver.Address, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]resolver.Address, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(ip, port)})
+	}
+	return addrs, nil
+}
+
+func lookupSRV(ctx context.Context, endpoint string) ([]resolver.Address, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]resolver.Address, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))})
+	}
+	return addrs, nil
+}
+
+// lookupBuilder builds resolvers which periodically call lookup, and when
+// gRPC asks, but no more often than every minInterval.
+type lookupBuilder struct {
+	scheme      string
+	refresh     time.Duration
+	minInterval time.Duration
+	lookup      func(ctx context.Context, endpoint string) ([]resolver.Address, error)
+}
+
+func (b *lookupBuilder) Scheme() string { return b.scheme }
+
+func (b *lookupBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	endpoint := target.Endpoint()
+	if endpoint == "" {
+		return nil, fmt.Errorf("missing address in %s target", b.scheme)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &lookupResolver{
+		builder:    b,
+		endpoint:   endpoint,
+		cc:         cc,
+		ctx:        ctx,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r, nil
+}
+
+type lookupResolver struct {
+	builder    *lookupBuilder
+	endpoint   string
+	cc         resolver.ClientConn
+	ctx        context.Context
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	wg         sync.WaitGroup
+}
+
+func (r *lookupResolver) loop() {
+	defer r.wg.Done()
+	for {
+		addrs, err := r.builder.lookup(r.ctx, r.endpoint)
+		resolved := time.Now()
+		if err != nil {
+			r.cc.ReportError(fmt.Errorf("error resolving %s: %v", r.endpoint, err))
+		} else {
+			r.cc.UpdateState(resolver.State{Addresses: addrs})
+		}
+
+		// With no refresh interval, only re-resolve when gRPC asks to.
+		var timer *time.Timer
+		var refresh <-chan time.Time
+		if r.builder.refresh > 0 {
+			timer = time.NewTimer(r.builder.refresh)
+			refresh = timer.C
+		}
+		select {
+		case <-r.ctx.Done():
+		case <-r.resolveNow:
+			wait := time.NewTimer(time.Until(resolved.Add(r.builder.minInterval)))
+			select {
+			case <-r.ctx.Done():
+			case <-wait.C:
+			}
+			wait.Stop()
+			// Any requests while waiting are answered by the next lookup.
+			select {
+			case <-r.resolveNow:
+			default:
+			}
+		case <-refresh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (r *lookupResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *lookupResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// staticBuilder builds resolvers for a fixed, comma-separated list of addresses.
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string { return staticScheme }
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, addr := range strings.Split(target.Endpoint(), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, resolver.Address{Addr: addr})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses in %s target", staticScheme)
+	}
+	cc.UpdateState(resolver.State{Addresses: addrs})
+	return staticResolver{}, nil
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (staticResolver) Close()                                {}
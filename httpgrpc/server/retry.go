@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks/common/grpc"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/instrument"
+)
+
+const (
+	// retryBudgetBurst is the most retries the budget allows in a row, and
+	// what it starts with.
+	retryBudgetBurst = 10
+
+	// Hedging starts once this many latencies have been observed, and the
+	// percentile is recomputed every time as many again have been.
+	latencyMinSamples = 100
+	// latencyWindow is how many recent latencies the percentile is taken over.
+	latencyWindow = 1000
+)
+
+var (
+	// defaultAttemptCollector is shared by Clients without an AttemptCollector,
+	// so it is only registered once.
+	defaultAttemptCollector = instrument.NewHistogramCollectorFromOpts(prometheus.HistogramOpts{
+		Namespace: "httpgrpc",
+		Name:      "client_attempt_duration_seconds",
+		Help:      "Time spent on each attempt at a request with retries or hedging, including retries and hedged requests.",
+		Buckets:   instrument.DefBuckets,
+	})
+	registerDefaultAttemptCollector sync.Once
+)
+
+// idempotentMethods are the HTTP methods whose requests can be retried or
+// hedged, as making them more than once has the same effect as making them once.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retryBudget limits retries and hedged requests to a fraction of requests,
+// as a token bucket: each request deposits ratio tokens, and each retry or
+// hedge withdraws one.
+type retryBudget struct {
+	mtx    sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, tokens: retryBudgetBurst}
+}
+
+func (b *retryBudget) deposit() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetBurst {
+		b.tokens = retryBudgetBurst
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// latencyTracker tracks a percentile of recent request latencies, to hedge
+// requests which take longer.
+type latencyTracker struct {
+	mtx         sync.Mutex
+	percentile  float64
+	samples     []time.Duration
+	next        int
+	sinceUpdate int
+	threshold   time.Duration
+}
+
+func newLatencyTracker(percentile float64) *latencyTracker {
+	return &latencyTracker{
+		percentile: percentile,
+		samples:    make([]time.Duration, 0, latencyWindow),
+	}
+}
+
+func (l *latencyTracker) observe(d time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % latencyWindow
+	}
+	l.sinceUpdate++
+	if len(l.samples) < latencyMinSamples || l.sinceUpdate < latencyMinSamples {
+		return
+	}
+	sorted := append([]time.Duration(nil), l.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	l.threshold = sorted[int(l.percentile*float64(len(sorted)))]
+	l.sinceUpdate = 0
+}
+
+// hedgeDelay returns how long to wait before hedging a request, or false if
+// not enough latencies have been observed yet.
+func (l *latencyTracker) hedgeDelay() (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.threshold, l.threshold > 0
+}
+
+// retryable returns true if err might not happen again if the request is
+// retried.
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return resp.Code/100 == 5
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// retries returns true if requests like r are retried or hedged. They are
+// sent with Handle, so their bodies can be sent again.
+func (c *Client) retries(r *http.Request) bool {
+	return (c.maxRetries > 0 || c.latencies != nil) && idempotentMethods[r.Method]
+}
+
+// handleWithRetries makes req, retrying with exponential backoff if it fails
+// with Unavailable or a 5xx status, as long as the budget allows.
+func (c *Client) handleWithRetries(ctx context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	c.budget.deposit()
+	backoff := c.minBackoff
+	operation := "httpgrpc attempt"
+	for attempt := 0; ; attempt++ {
+		resp, err := c.handleWithHedging(ctx, operation, req)
+		if !retryable(err) || attempt >= c.maxRetries || !c.budget.withdraw() {
+			return resp, err
+		}
+
+		// Jitter the backoff, so clients which failed together don't retry
+		// together.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return resp, err
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+		operation = "httpgrpc retry"
+	}
+}
+
+// handleWithHedging makes req, and if it hasn't completed after the hedging
+// percentile of recent latencies, makes it again and takes whichever response
+// comes back first.
+func (c *Client) handleWithHedging(ctx context.Context, operation string, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	delay, ok := c.latencies.hedgeDelay()
+	if !ok {
+		return c.attempt(ctx, operation, req)
+	}
+
+	// The slower attempt is cancelled once we have a response.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		resp *httpgrpc.HTTPResponse
+		err  error
+	}
+	results := make(chan result, 2)
+	do := func(operation string) {
+		resp, err := c.attempt(ctx, operation, req)
+		results <- result{resp, err}
+	}
+	go do(operation)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case res := <-results:
+			// If the first attempt to complete failed, give the other a chance.
+			if pending--; pending == 0 || !retryable(res.err) {
+				return res.resp, res.err
+			}
+		case <-timer.C:
+			if c.budget.withdraw() {
+				go do("httpgrpc hedge")
+				pending++
+			}
+		}
+	}
+}
+
+// attempt makes req once, with a span and metrics for it.
+func (c *Client) attempt(ctx context.Context, operation string, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	var resp *httpgrpc.HTTPResponse
+	toStatusCode := func(err error) string {
+		if err == nil {
+			return strconv.Itoa(int(resp.Code))
+		}
+		if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+			return strconv.Itoa(int(errResp.Code))
+		}
+		if grpc.IsCanceled(err) {
+			return "cancel"
+		}
+		return "error"
+	}
+	err := instrument.CollectedRequest(ctx, operation, c.collector, toStatusCode, func(ctx context.Context) error {
+		start := time.Now()
+		var err error
+		resp, err = c.client.Handle(ctx, req)
+		if err == nil && c.latencies != nil {
+			c.latencies.observe(time.Since(start))
+		}
+		return err
+	})
+	return resp, err
+}
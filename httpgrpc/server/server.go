@@ -2,37 +2,64 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
-	"github.com/sercand/kuberesolver/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/instrument"
 	"github.com/weaveworks/common/logging"
-	"github.com/weaveworks/common/middleware"
 )
 
-// Server implements HTTPServer.  HTTPServer is a generated interface that gRPC
-// servers must implement.
+// Server implements HTTPServer and HTTPStreamServer.  HTTPServer is a generated
+// interface that gRPC servers must implement; HTTPStreamServer is optional, but
+// lets Clients stream request and response bodies.
 type Server struct {
-	handler http.Handler
+	handler       http.Handler
+	trustTLSState bool
+
+	uncompressedBytes *prometheus.CounterVec
+	compressedBytes   *prometheus.CounterVec
 }
 
+// ServerOption configures a Server made by NewServer.
+type ServerOption func(*Server)
+
 // NewServer makes a new Server.
-func NewServer(handler http.Handler) *Server {
-	return &Server{
+func NewServer(handler http.Handler, opts ...ServerOption) *Server {
+	s := &Server{
 		handler: handler,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithTrustedProxyTLS makes the Server pass on the TLS state, including the
+// certificates of the client of the proxy, sent with each request. Clients can
+// send any state, so only use this if they are all trusted proxies. Otherwise
+// requests have no TLS state.
+func WithTrustedProxyTLS() ServerOption {
+	return func(s *Server) {
+		s.trustTLSState = true
+	}
 }
 
 type nopCloser struct {
@@ -46,42 +73,101 @@ func (n nopCloser) BytesBuffer() *bytes.Buffer { return n.Buffer }
 
 // Handle implements HTTPServer.
 func (s Server) Handle(ctx context.Context, r *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
-	req, err := http.NewRequest(r.Method, r.Url, nopCloser{Buffer: bytes.NewBuffer(r.Body)})
+	req, err := s.newRequest(ctx, r, nopCloser{Buffer: bytes.NewBuffer(r.Body)})
 	if err != nil {
 		return nil, err
 	}
-	toHeader(r.Headers, req.Header)
-	req = req.WithContext(ctx)
-	req.RequestURI = r.Url
 	req.ContentLength = int64(len(r.Body))
+	if len(r.Trailers) > 0 {
+		req.Trailer = http.Header{}
+		toHeader(r.Trailers, req.Trailer)
+	}
 
 	recorder := httptest.NewRecorder()
 	s.handler.ServeHTTP(recorder, req)
+	headers, trailers := splitTrailers(recorder.Header())
 	resp := &httpgrpc.HTTPResponse{
-		Code:    int32(recorder.Code),
-		Headers: fromHeader(recorder.Header()),
-		Body:    recorder.Body.Bytes(),
+		Code:     int32(recorder.Code),
+		Headers:  headers,
+		Body:     recorder.Body.Bytes(),
+		Trailers: trailers,
 	}
 	if recorder.Code/100 == 5 {
 		return nil, httpgrpc.ErrorFromHTTPResponse(resp)
 	}
+	if err := s.compressResponse(r.AcceptBodyEncodings, recorder.Header(), resp); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
+// newRequest rehydrates the *http.Request sent by a Client, apart from its
+// trailers.
+func (s Server) newRequest(ctx context.Context, r *httpgrpc.HTTPRequest, body io.ReadCloser) (*http.Request, error) {
+	req, err := http.NewRequest(r.Method, r.Url, body)
+	if err != nil {
+		return nil, err
+	}
+	toHeader(r.Headers, req.Header)
+	req = req.WithContext(ctx)
+	req.RequestURI = r.Url
+
+	// These are missing from requests sent by older clients.
+	req.RemoteAddr = r.RemoteAddr
+	if r.Host != "" {
+		req.Host = r.Host
+	}
+	if major, minor, ok := http.ParseHTTPVersion(r.Proto); ok {
+		req.Proto, req.ProtoMajor, req.ProtoMinor = r.Proto, major, minor
+	}
+	if s.trustTLSState {
+		if req.TLS, err = toTLSState(r.TLS); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
 // Client is a http.Handler that forwards the request over gRPC.
 type Client struct {
 	client httpgrpc.HTTPClient
+	stream httpgrpc.HTTPStreamClient
 	conn   *grpc.ClientConn
+
+	// Encodings the server may compress response bodies with, and the most
+	// the client will decompress a buffered response body to.
+	encodings   []string
+	maxBodySize int
+
+	maxRetries             int
+	minBackoff, maxBackoff time.Duration
+	budget                 *retryBudget
+	latencies              *latencyTracker // nil if hedging is disabled.
+	collector              instrument.Collector
+
+	// Set if streaming is disabled, or once the server has been found not to
+	// implement HandleStream.
+	streamUnsupported int32
+	abortIncomplete   bool
 }
 
 // ParseURL deals with direct:// style URLs, as well as kubernetes:// urls.
 // For backwards compatibility it treats URLs without schems as kubernetes://.
+//
+// It also understands dns://host:port, which is re-resolved periodically,
+// dns+srv://name, which looks up SRV records, and static://host:port,host:port.
+// These need the resolvers set up by NewClientWithConfig.
 func ParseURL(unparsed string) (string, error) {
 	// if it has :///, this is the kuberesolver v2 URL. Return it as it is.
 	if strings.Contains(unparsed, ":///") {
 		return unparsed, nil
 	}
 
+	// A list of hosts isn't a valid URL host, so don't try to parse it.
+	if hosts := strings.TrimPrefix(unparsed, staticScheme+"://"); hosts != unparsed {
+		return staticScheme + ":///" + hosts, nil
+	}
+
 	parsed, err := url.Parse(unparsed)
 	if err != nil {
 		return "", err
@@ -96,6 +182,12 @@ func ParseURL(unparsed string) (string, error) {
 	case "direct":
 		return host, err
 
+	case "dns":
+		return dnsScheme + ":///" + host, nil
+
+	case dnsSRVScheme:
+		return dnsSRVScheme + ":///" + host, nil
+
 	case "kubernetes":
 		host, port, err := net.SplitHostPort(host)
 		if err != nil {
@@ -118,34 +210,12 @@ func ParseURL(unparsed string) (string, error) {
 	}
 }
 
-// NewClient makes a new Client, given a kubernetes service address.
+// NewClient makes a new Client, given a kubernetes service address, using the
+// default ClientConfig.
 func NewClient(address string) (*Client, error) {
-	kuberesolver.RegisterInCluster()
-
-	address, err := ParseURL(address)
-	if err != nil {
-		return nil, err
-	}
-	const grpcServiceConfig = `{"loadBalancingPolicy":"round_robin"}`
-
-	dialOptions := []grpc.DialOption{
-		grpc.WithDefaultServiceConfig(grpcServiceConfig),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithChainUnaryInterceptor(
-			otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
-			middleware.ClientUserHeaderInterceptor,
-		),
-	}
-
-	conn, err := grpc.Dial(address, dialOptions...)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Client{
-		client: httpgrpc.NewHTTPClient(conn),
-		conn:   conn,
-	}, nil
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	return NewClientWithConfig(address, cfg)
 }
 
 // HTTPRequest wraps an ordinary HTTPRequest with a gRPC one
@@ -154,30 +224,89 @@ func HTTPRequest(r *http.Request) (*httpgrpc.HTTPRequest, error) {
 	if err != nil {
 		return nil, err
 	}
+	req := newHTTPRequest(r)
+	req.Body = body
+	// Trailers are only set once the body has been read.
+	req.Trailers = fromHeader(r.Trailer)
+	return req, nil
+}
+
+// newHTTPRequest converts everything but the body and trailers of r.
+func newHTTPRequest(r *http.Request) *httpgrpc.HTTPRequest {
 	return &httpgrpc.HTTPRequest{
-		Method:  r.Method,
-		Url:     r.RequestURI,
-		Body:    body,
-		Headers: fromHeader(r.Header),
-	}, nil
+		Method:     r.Method,
+		Url:        r.RequestURI,
+		Headers:    fromHeader(r.Header),
+		RemoteAddr: r.RemoteAddr,
+		Host:       r.Host,
+		Proto:      r.Proto,
+		TLS:        fromTLSState(r.TLS),
+	}
+}
+
+// acceptBodyEncodings returns the encodings the server may compress the
+// response to r with, preferring those r accepts, so they can be passed on.
+func (c *Client) acceptBodyEncodings(r *http.Request) []string {
+	if len(c.encodings) == 0 {
+		return nil
+	}
+	accept := make([]string, 0, len(c.encodings))
+	for _, name := range c.encodings {
+		if acceptsEncoding(r.Header, name) {
+			accept = append(accept, name)
+		}
+	}
+	for _, name := range c.encodings {
+		if !acceptsEncoding(r.Header, name) {
+			accept = append(accept, name)
+		}
+	}
+	return accept
 }
 
-// WriteResponse converts an httpgrpc response to an HTTP one
+// WriteResponse converts an httpgrpc response to an HTTP one, decompressing
+// the body if the server compressed it.
 func WriteResponse(w http.ResponseWriter, resp *httpgrpc.HTTPResponse) error {
+	return writeResponse(w, resp, defaultMaxDecompressedSize)
+}
+
+// writeResponse is WriteResponse, with a limit on the decompressed body size.
+func writeResponse(w http.ResponseWriter, resp *httpgrpc.HTTPResponse, maxBodySize int) error {
+	if err := decompressResponse(resp, maxBodySize); err != nil {
+		return err
+	}
 	toHeader(resp.Headers, w.Header())
 	w.WriteHeader(int(resp.Code))
 	_, err := w.Write(resp.Body)
+	writeTrailers(w, resp.Trailers)
 	return err
 }
 
-// WriteError converts an httpgrpc error to an HTTP one
+// writeTrailers sets trailers on w, once the header has been written.
+func writeTrailers(w http.ResponseWriter, trailers []*httpgrpc.Header) {
+	for _, t := range trailers {
+		w.Header()[http.TrailerPrefix+t.Key] = t.Values
+	}
+}
+
+// WriteError converts an httpgrpc error to an HTTP one. Errors with details
+// are written as RFC 7807 problem details, with the original body as the
+// detail member. Other gRPC errors get the HTTP status code equivalent to
+// their gRPC code.
 func WriteError(w http.ResponseWriter, err error) {
 	resp, ok := httpgrpc.HTTPResponseFromError(err)
-	if ok {
-		WriteResponse(w, resp)
-	} else {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !ok {
+		code := http.StatusInternalServerError
+		if s, ok := status.FromError(err); ok {
+			code = httpgrpc.HTTPStatusFromGRPCCode(s.Code())
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if details := httpgrpc.Details(err); len(details) > 0 && writeProblem(w, resp, details) {
+		return
 	}
+	WriteResponse(w, resp)
 }
 
 // ServeHTTP implements http.Handler
@@ -190,24 +319,49 @@ func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	retry := c.retries(r)
+	if !retry && atomic.LoadInt32(&c.streamUnsupported) == 0 {
+		served, err := c.serveStream(w, r)
+		if err != nil {
+			logging.Global().Warnf("error streaming response body: %v", err)
+			if c.abortIncomplete {
+				// The status code has been sent, so this is the only way left
+				// to tell the client the response is incomplete.
+				panic(http.ErrAbortHandler)
+			}
+		}
+		if served {
+			return
+		}
+	}
+
 	req, err := HTTPRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resp, err := c.client.Handle(r.Context(), req)
+	req.AcceptBodyEncodings = c.acceptBodyEncodings(r)
+	var resp *httpgrpc.HTTPResponse
+	if retry {
+		resp, err = c.handleWithRetries(r.Context(), req)
+	} else {
+		resp, err = c.client.Handle(r.Context(), req)
+	}
 	if err != nil {
-		// Some errors will actually contain a valid resp, just need to unpack it
-		var ok bool
-		resp, ok = httpgrpc.HTTPResponseFromError(err)
+		// Some errors will actually contain a valid resp, which WriteError
+		// unpacks.
+		WriteError(w, err)
+		return
+	}
 
-		if !ok {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	if resp.BodyEncoding != "" {
+		toHeader(resp.Headers, w.Header())
+		resp.Headers = nil
+		if passThroughEncoding(r, resp.BodyEncoding, w.Header()) {
+			resp.BodyEncoding = ""
 		}
 	}
-
-	if err := WriteResponse(w, resp); err != nil {
+	if err := writeResponse(w, resp, c.maxBodySize); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -219,6 +373,72 @@ func toHeader(hs []*httpgrpc.Header, header http.Header) {
 	}
 }
 
+// splitTrailers splits the headers set by a handler into the headers and the
+// trailers of the response. Trailers are either declared in the Trailer header
+// or prefixed with http.TrailerPrefix.
+func splitTrailers(h http.Header) (headers, trailers []*httpgrpc.Header) {
+	declared := map[string]bool{}
+	for _, v := range h["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				declared[http.CanonicalHeaderKey(k)] = true
+			}
+		}
+	}
+
+	headers = make([]*httpgrpc.Header, 0, len(h))
+	for k, vs := range h {
+		switch {
+		case strings.HasPrefix(k, http.TrailerPrefix):
+			trailers = append(trailers, &httpgrpc.Header{Key: strings.TrimPrefix(k, http.TrailerPrefix), Values: vs})
+		case declared[k]:
+			trailers = append(trailers, &httpgrpc.Header{Key: k, Values: vs})
+		default:
+			headers = append(headers, &httpgrpc.Header{Key: k, Values: vs})
+		}
+	}
+	return headers, trailers
+}
+
+func fromTLSState(cs *tls.ConnectionState) *httpgrpc.TLSState {
+	if cs == nil {
+		return nil
+	}
+	state := &httpgrpc.TLSState{
+		Version:            uint32(cs.Version),
+		CipherSuite:        uint32(cs.CipherSuite),
+		ServerName:         cs.ServerName,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+	}
+	for _, cert := range cs.PeerCertificates {
+		state.PeerCertificates = append(state.PeerCertificates, cert.Raw)
+	}
+	return state
+}
+
+// toTLSState converts the TLS state sent by a trusted proxy, which only sends
+// it for connections whose handshake is complete.
+func toTLSState(state *httpgrpc.TLSState) (*tls.ConnectionState, error) {
+	if state == nil {
+		return nil, nil
+	}
+	cs := &tls.ConnectionState{
+		Version:            uint16(state.Version),
+		CipherSuite:        uint16(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		HandshakeComplete:  true,
+	}
+	for _, der := range state.PeerCertificates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing peer certificate: %v", err)
+		}
+		cs.PeerCertificates = append(cs.PeerCertificates, cert)
+	}
+	return cs, nil
+}
+
 func fromHeader(hs http.Header) []*httpgrpc.Header {
 	result := make([]*httpgrpc.Header, 0, len(hs))
 	for k, vs := range hs {
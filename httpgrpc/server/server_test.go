@@ -1,19 +1,34 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/proto"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
 
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/middleware"
@@ -26,19 +41,20 @@ type testServer struct {
 	grpcServer *grpc.Server
 }
 
-func newTestServer(handler http.Handler) (*testServer, error) {
+func newTestServer(handler http.Handler, opts ...ServerOption) (*testServer, error) {
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		return nil, err
 	}
 
 	server := &testServer{
-		Server:     NewServer(handler),
+		Server:     NewServer(handler, opts...),
 		grpcServer: grpc.NewServer(),
 		URL:        "direct://" + lis.Addr().String(),
 	}
 
 	httpgrpc.RegisterHTTPServer(server.grpcServer, server.Server)
+	httpgrpc.RegisterHTTPStreamServer(server.grpcServer, server.Server)
 	go server.grpcServer.Serve(lis)
 
 	return server, nil
@@ -87,6 +103,746 @@ func TestError(t *testing.T) {
 	assert.Equal(t, 500, recorder.Code)
 }
 
+func TestStreaming(t *testing.T) {
+	unblock := make(chan struct{})
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		fmt.Fprintf(w, "%d\n", len(body))
+		w.(http.Flusher).Flush()
+		<-unblock
+		w.Write(bytes.Repeat([]byte("y"), 3*streamChunkSize+5))
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	client, err := NewClient(server.URL)
+	require.NoError(t, err)
+	front := httptest.NewServer(middleware.AuthenticateUser.Wrap(client))
+	defer front.Close()
+
+	// Both bodies are bigger than a single message.
+	body := bytes.Repeat([]byte("x"), 10*streamChunkSize+1)
+	req, err := http.NewRequest("POST", front.URL+"/hello", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(user.OrgIDHeaderName, "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	// The flushed part of the response arrives before the handler finishes.
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d\n", len(body)), line)
+
+	close(unblock)
+	rest, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, 3*streamChunkSize+5, len(rest))
+	assert.Equal(t, int32(0), client.streamUnsupported)
+}
+
+func TestStreamingFallback(t *testing.T) {
+	// Servers from before HandleStream was added only have the HTTP service.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	defer grpcServer.GracefulStop()
+	httpgrpc.RegisterHTTPServer(grpcServer, NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Write(body)
+	})))
+	go grpcServer.Serve(lis)
+
+	client, err := NewClient("direct://" + lis.Addr().String())
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", "/hello", bytes.NewBufferString("world"))
+		require.NoError(t, err)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+		recorder := httptest.NewRecorder()
+		client.ServeHTTP(recorder, req)
+
+		assert.Equal(t, 200, recorder.Code)
+		assert.Equal(t, "world", recorder.Body.String())
+		assert.Equal(t, int32(1), client.streamUnsupported)
+	}
+}
+
+func TestStreamingDisabled(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.DisableStreaming = true
+	var streams int32
+	cfg.StreamInterceptors = []grpc.StreamClientInterceptor{
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			atomic.AddInt32(&streams, 1)
+			return streamer(ctx, desc, cc, method, opts...)
+		},
+	}
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/hello", bytes.NewBufferString("hello"))
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "world", recorder.Body.String())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&streams))
+}
+
+func TestStreamingEarlyResponse(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	client, err := NewClient(server.URL)
+	require.NoError(t, err)
+	front := httptest.NewServer(middleware.AuthenticateUser.Wrap(client))
+	defer front.Close()
+
+	// The response arrives before the body has been sent, which must not be
+	// read once the client's handler returns.
+	for i := 0; i < 10; i++ {
+		req, err := http.NewRequest("POST", front.URL+"/hello", bytes.NewReader(bytes.Repeat([]byte("x"), 100*streamChunkSize)))
+		require.NoError(t, err)
+		req.Header.Set(user.OrgIDHeaderName, "1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+// flushNotifier closes flushed when the response is first flushed.
+type flushNotifier struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+	once    sync.Once
+}
+
+func (w *flushNotifier) Flush() {
+	w.ResponseRecorder.Flush()
+	w.once.Do(func() { close(w.flushed) })
+}
+
+func TestStreamingIncompleteResponse(t *testing.T) {
+	for _, abort := range []bool{false, true} {
+		server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "partial")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		require.NoError(t, err)
+
+		var cfg ClientConfig
+		cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+		cfg.AbortIncompleteResponses = abort
+		client, err := NewClientWithConfig(server.URL, cfg)
+		require.NoError(t, err)
+
+		// The server goes away once the start of the response has arrived.
+		recorder := &flushNotifier{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{})}
+		go func() {
+			<-recorder.flushed
+			server.grpcServer.Stop()
+		}()
+		req := httptest.NewRequest("GET", "/hello", nil)
+		serve := func() {
+			client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+		}
+		if abort {
+			assert.PanicsWithValue(t, http.ErrAbortHandler, serve)
+		} else {
+			assert.NotPanics(t, serve)
+		}
+		assert.Equal(t, 200, recorder.Code)
+		assert.Equal(t, "partial", recorder.Body.String())
+	}
+}
+
+func TestRequestFields(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "192.0.2.1:1234", r.RemoteAddr)
+		assert.Equal(t, "example.com", r.Host)
+		assert.Equal(t, "HTTP/1.1", r.Proto)
+		assert.Equal(t, 1, r.ProtoMinor)
+		assert.Nil(t, r.TLS)
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+		assert.Equal(t, "request", r.Trailer.Get("X-Request-Trailer"))
+
+		w.Header().Set("Trailer", "X-Declared-Trailer")
+		w.Write([]byte("world"))
+		w.Header().Set("X-Declared-Trailer", "declared")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared-Trailer", "undeclared")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	client, err := NewClient(server.URL)
+	require.NoError(t, err)
+
+	// Over HandleStream, then Handle.
+	for _, unsupported := range []int32{0, 1} {
+		client.streamUnsupported = unsupported
+		req := httptest.NewRequest("POST", "http://example.com/hello", bytes.NewBufferString("hello"))
+		req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+		req.Trailer = http.Header{"X-Request-Trailer": {"request"}}
+		recorder := httptest.NewRecorder()
+		client.ServeHTTP(recorder, req)
+
+		resp := recorder.Result()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "world", recorder.Body.String())
+		assert.Equal(t, "declared", resp.Trailer.Get("X-Declared-Trailer"))
+		assert.Equal(t, "undeclared", resp.Trailer.Get("X-Undeclared-Trailer"))
+		assert.Empty(t, resp.Header.Get("X-Declared-Trailer"))
+	}
+}
+
+func TestTrustedProxyTLS(t *testing.T) {
+	for _, trusted := range []bool{false, true} {
+		var opts []ServerOption
+		if trusted {
+			opts = append(opts, WithTrustedProxyTLS())
+		}
+		server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trusted {
+				assert.Nil(t, r.TLS)
+				return
+			}
+			require.NotNil(t, r.TLS)
+			assert.Equal(t, "example.com", r.TLS.ServerName)
+			assert.True(t, r.TLS.HandshakeComplete)
+		}), opts...)
+		require.NoError(t, err)
+		defer server.grpcServer.GracefulStop()
+
+		client, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		// Over HandleStream, then Handle.
+		for _, unsupported := range []int32{0, 1} {
+			client.streamUnsupported = unsupported
+			req := httptest.NewRequest("GET", "https://example.com/hello", nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+			recorder := httptest.NewRecorder()
+			client.ServeHTTP(recorder, req)
+			assert.Equal(t, 200, recorder.Code)
+		}
+	}
+}
+
+func TestTLSState(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	cert := srv.Certificate()
+
+	state, err := toTLSState(fromTLSState(&tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "example.com",
+		NegotiatedProtocol: "h2",
+		PeerCertificates:   []*x509.Certificate{cert},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version)
+	assert.Equal(t, tls.TLS_AES_128_GCM_SHA256, state.CipherSuite)
+	assert.Equal(t, "example.com", state.ServerName)
+	assert.Equal(t, "h2", state.NegotiatedProtocol)
+	assert.True(t, state.HandshakeComplete)
+	require.Len(t, state.PeerCertificates, 1)
+	assert.True(t, cert.Equal(state.PeerCertificates[0]))
+
+	_, err = toTLSState(&httpgrpc.TLSState{PeerCertificates: [][]byte{[]byte("bogus")}})
+	assert.Error(t, err)
+}
+
+// errorServer fails every request with err.
+type errorServer struct {
+	err error
+}
+
+func (s errorServer) Handle(context.Context, *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	return nil, s.err
+}
+
+func TestErrorDetails(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	defer grpcServer.GracefulStop()
+	httpgrpc.RegisterHTTPServer(grpcServer, errorServer{err: httpgrpc.ErrorfWithDetails(http.StatusTooManyRequests, []proto.Message{
+		httpgrpc.RetryInfo(1500 * time.Millisecond),
+		&rpc.ErrorInfo{Reason: "RATE_LIMITED", Domain: "example.com", Metadata: map[string]string{"limit": "10"}},
+		&rpc.BadRequest{FieldViolations: []*rpc.BadRequest_FieldViolation{{Field: "query", Description: "too long"}}},
+		&rpc.QuotaFailure{Violations: []*rpc.QuotaFailure_Violation{{Subject: "tenant:1", Description: "over quota"}}},
+	}, "slow down")})
+	go grpcServer.Serve(lis)
+
+	client, err := NewClient("direct://" + lis.Addr().String())
+	require.NoError(t, err)
+
+	// The details survive the round trip.
+	_, err = client.client.Handle(user.InjectOrgID(context.Background(), "1"), &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"})
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	assert.Len(t, httpgrpc.Details(err), 4)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/hello", nil)
+	client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Too Many Requests",
+		"status": 429,
+		"detail": "slow down",
+		"reason": "RATE_LIMITED",
+		"domain": "example.com",
+		"metadata": {"limit": "10"},
+		"retry-after": 1.5,
+		"invalid-params": [{"name": "query", "reason": "too long"}],
+		"quota-violations": [{"subject": "tenant:1", "description": "over quota"}]
+	}`, recorder.Body.String())
+}
+
+func TestWriteErrorWithoutDetails(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	WriteError(recorder, httpgrpc.Errorf(http.StatusBadRequest, "bad"))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "bad", recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get("Content-Type"))
+}
+
+func TestCompression(t *testing.T) {
+	body := strings.Repeat("hello world ", 1000)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "small")
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		fmt.Fprint(w, body)
+	})
+	uncompressed := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "uncompressed_bytes_total"}, []string{"encoding"})
+	compressed := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "compressed_bytes_total"}, []string{"encoding"})
+	server, err := newTestServer(mux, WithCompressionMetrics(uncompressed, compressed))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.Compression = "zstd,snappy,gzip"
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+
+	// Over HandleStream, then Handle.
+	for _, unsupported := range []int32{0, 1} {
+		client.streamUnsupported = unsupported
+		for _, tc := range []struct {
+			path, acceptEncoding, contentEncoding, body string
+		}{
+			// Decompressed by the client.
+			{"/plain", "", "", body},
+			{"/plain", "gzip;q=0", "", body},
+			// Passed through to the original client.
+			{"/plain", "deflate, gzip", "gzip", body},
+			{"/plain", "snappy", "snappy", body},
+			{"/small", "gzip", "", "small"},
+			{"/encoded", "gzip", "br", body},
+		} {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			recorder := httptest.NewRecorder()
+			client.ServeHTTP(recorder, req)
+
+			assert.Equal(t, 200, recorder.Code)
+			assert.Equal(t, tc.contentEncoding, recorder.Header().Get("Content-Encoding"))
+			got := recorder.Body.String()
+			if getCompressor(tc.contentEncoding) != nil {
+				r, err := decompressBody(tc.contentEncoding, recorder.Body)
+				require.NoError(t, err)
+				b, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				got = string(b)
+			}
+			assert.Equal(t, tc.body, got, "%s %q", tc.path, tc.acceptEncoding)
+		}
+	}
+
+	for _, encoding := range []string{"gzip", "snappy", "zstd"} {
+		assert.Less(t, testutil.ToFloat64(compressed.WithLabelValues(encoding)), testutil.ToFloat64(uncompressed.WithLabelValues(encoding))/10)
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 64*1024))
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.Compression = "gzip"
+	cfg.MaxRecvMsgSize = 32 * 1024
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+	client.streamUnsupported = 1
+
+	// The compressed body fits in a message, but would be too big once
+	// buffered and decompressed.
+	req := httptest.NewRequest("GET", "/hello", nil)
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "decompressed response body is bigger than 32768 bytes")
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		header   string
+		accepted bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"br", false},
+	} {
+		h := http.Header{"Accept-Encoding": {tc.header}}
+		assert.Equal(t, tc.accepted, acceptsEncoding(h, "gzip"), tc.header)
+	}
+}
+
+// attemptCollector records the operation and status code of attempts.
+type attemptCollector struct {
+	mtx      sync.Mutex
+	attempts []string
+}
+
+func (c *attemptCollector) Register()                                 {}
+func (c *attemptCollector) Before(context.Context, string, time.Time) {}
+func (c *attemptCollector) After(_ context.Context, method, statusCode string, _ time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.attempts = append(c.attempts, method+" "+statusCode)
+}
+
+func TestRetries(t *testing.T) {
+	var hits int32
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&hits, 1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/broken" {
+			atomic.AddInt32(&hits, 1)
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	collector := &attemptCollector{}
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.MaxRetries = 3
+	cfg.RetryMinBackoff = time.Millisecond
+	cfg.RetryMaxBackoff = time.Millisecond
+	cfg.RetryBudgetRatio = 0
+	cfg.AttemptCollector = collector
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		recorder := httptest.NewRecorder()
+		client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+		return recorder
+	}
+
+	recorder := serve("GET", "/flaky")
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "world", recorder.Body.String())
+	assert.Equal(t, []string{"httpgrpc attempt 503", "httpgrpc retry 503", "httpgrpc retry 200"}, collector.attempts)
+
+	// Requests which aren't idempotent aren't retried.
+	atomic.StoreInt32(&hits, 0)
+	recorder = serve("POST", "/flaky")
+	assert.Equal(t, 503, recorder.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// With nothing deposited, the budget runs out after the initial burst,
+	// less the two retries above.
+	atomic.StoreInt32(&hits, 0)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 500, serve("GET", "/broken").Code)
+	}
+	assert.Equal(t, int32(5+retryBudgetBurst-2), atomic.LoadInt32(&hits))
+}
+
+func TestDefaultAttemptCollector(t *testing.T) {
+	var hits int32
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.MaxRetries = 1
+	cfg.RetryMinBackoff = time.Millisecond
+	cfg.RetryMaxBackoff = time.Millisecond
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+	// A second client shares the same histogram.
+	_, err = NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+
+	attempts := func() map[string]uint64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		counts := map[string]uint64{}
+		for _, family := range families {
+			if family.GetName() != "httpgrpc_client_attempt_duration_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				var labels []string
+				for _, l := range m.GetLabel() {
+					labels = append(labels, l.GetValue())
+				}
+				counts[strings.Join(labels, " ")] = m.GetHistogram().GetSampleCount()
+			}
+		}
+		return counts
+	}
+	before := attempts()
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	assert.Equal(t, 200, recorder.Code)
+
+	after := attempts()
+	assert.Equal(t, before["httpgrpc attempt 503"]+1, after["httpgrpc attempt 503"])
+	assert.Equal(t, before["httpgrpc retry 200"]+1, after["httpgrpc retry 200"])
+}
+
+func TestHedging(t *testing.T) {
+	var hits int32
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request hangs until it's cancelled.
+		if atomic.AddInt32(&hits, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	collector := &attemptCollector{}
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.HedgeAfterPercentile = 0.9
+	cfg.AttemptCollector = collector
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+	for i := 0; i < latencyMinSamples; i++ {
+		client.latencies.observe(10 * time.Millisecond)
+	}
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "world", recorder.Body.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// The slow attempt is cancelled once the hedge has responded.
+	require.Eventually(t, func() bool {
+		collector.mtx.Lock()
+		defer collector.mtx.Unlock()
+		return len(collector.attempts) == 2
+	}, time.Second, time.Millisecond)
+	assert.ElementsMatch(t, []string{"httpgrpc hedge 200", "httpgrpc attempt cancel"}, collector.attempts)
+}
+
+func TestNewClientWithConfig(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.DisableKubernetesResolver = true
+	var streams int
+	cfg.StreamInterceptors = []grpc.StreamClientInterceptor{
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			streams++
+			return streamer(ctx, desc, cc, method, opts...)
+		},
+	}
+	client, err := NewClientWithConfig(server.URL, cfg)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("GET", "/hello", &bytes.Buffer{})
+	require.NoError(t, err)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req)
+
+	assert.Equal(t, "world", recorder.Body.String())
+	assert.Equal(t, 1, streams)
+}
+
+func TestClientConfigErrors(t *testing.T) {
+	var cfg ClientConfig
+	_, err := NewClientWithConfig("direct://foo", cfg)
+	require.EqualError(t, err, "load balancing policy must be set")
+
+	cfg.LoadBalancingPolicy = "round_robin"
+	cfg.TLSEnabled = true
+	cfg.TLS.CertPath = "client.crt"
+	_, err = NewClientWithConfig("direct://foo", cfg)
+	require.EqualError(t, err, "both the client certificate and key must be set")
+
+	cfg.TLS.CertPath = ""
+	cfg.TLS.CAPath = "/nonexistent/ca.crt"
+	_, err = NewClientWithConfig("direct://foo", cfg)
+	require.Error(t, err)
+
+	cfg.TLSEnabled = false
+	cfg.Compression = "gzip,br"
+	_, err = NewClientWithConfig("direct://foo", cfg)
+	require.EqualError(t, err, `unknown compression "br"`)
+
+	cfg.Compression = ""
+	cfg.HedgeAfterPercentile = 1
+	_, err = NewClientWithConfig("direct://foo", cfg)
+	require.EqualError(t, err, "hedge percentile must be at least 0 and less than 1")
+}
+
+func TestStaticResolver(t *testing.T) {
+	var hits [2]int32
+	var hosts []string
+	for i := range hits {
+		i := i
+		server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits[i], 1)
+		}))
+		require.NoError(t, err)
+		defer server.grpcServer.GracefulStop()
+		hosts = append(hosts, strings.TrimPrefix(server.URL, "direct://"))
+	}
+
+	client, err := NewClient("static://" + strings.Join(hosts, ","))
+	require.NoError(t, err)
+
+	// round_robin spreads requests over both servers once they're connected.
+	require.Eventually(t, func() bool {
+		req, err := http.NewRequest("GET", "/hello", &bytes.Buffer{})
+		require.NoError(t, err)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+		client.ServeHTTP(httptest.NewRecorder(), req)
+		return atomic.LoadInt32(&hits[0]) > 0 && atomic.LoadInt32(&hits[1]) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDNSResolver(t *testing.T) {
+	server, err := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "world")
+	}))
+	require.NoError(t, err)
+	defer server.grpcServer.GracefulStop()
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "direct://"))
+	require.NoError(t, err)
+	client, err := NewClient("dns://localhost:" + port)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("GET", "/hello", &bytes.Buffer{})
+	require.NoError(t, err)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "1"))
+	recorder := httptest.NewRecorder()
+	client.ServeHTTP(recorder, req)
+	assert.Equal(t, "world", recorder.Body.String())
+}
+
+// resolverClientConn records the addresses a resolver finds.
+type resolverClientConn struct {
+	resolver.ClientConn
+	updates int32
+}
+
+func (cc *resolverClientConn) UpdateState(resolver.State) error {
+	atomic.AddInt32(&cc.updates, 1)
+	return nil
+}
+
+func TestLookupResolverResolveNow(t *testing.T) {
+	build := func(minInterval time.Duration) (resolver.Resolver, *resolverClientConn) {
+		builder := &lookupBuilder{scheme: dnsScheme, minInterval: minInterval, lookup: func(context.Context, string) ([]resolver.Address, error) {
+			return []resolver.Address{{Addr: "localhost:80"}}, nil
+		}}
+		cc := &resolverClientConn{}
+		r, err := builder.Build(resolver.Target{URL: url.URL{Scheme: dnsScheme, Path: "/localhost:80"}}, cc, resolver.BuildOptions{})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&cc.updates) == 1 }, time.Second, time.Millisecond)
+		return r, cc
+	}
+
+	r, cc := build(0)
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&cc.updates) == 2 }, time.Second, time.Millisecond)
+	r.Close()
+
+	// Repeated requests soon after resolving wait for the interval to pass.
+	r, cc = build(time.Hour)
+	for i := 0; i < 10; i++ {
+		r.ResolveNow(resolver.ResolveNowOptions{})
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&cc.updates))
+	r.Close()
+}
+
 func TestParseURL(t *testing.T) {
 	for _, tc := range []struct {
 		input    string
@@ -99,6 +855,9 @@ func TestParseURL(t *testing.T) {
 		{"foo.bar.svc.local:995", "kubernetes:///foo.bar.svc.local:995", ""},
 		{"kubernetes:///foo:123", "kubernetes:///foo:123", ""},
 		{"dns:///foo.bar.svc.local:995", "dns:///foo.bar.svc.local:995", ""},
+		{"dns://foo.bar.svc.local:995", "dns+poll:///foo.bar.svc.local:995", ""},
+		{"dns+srv://_grpc._tcp.foo.bar.svc.local", "dns+srv:///_grpc._tcp.foo.bar.svc.local", ""},
+		{"static://foo:995,bar:995", "static:///foo:995,bar:995", ""},
 		{"monster://foo:995", "", "unrecognised scheme: monster"},
 	} {
 		got, err := ParseURL(tc.input)
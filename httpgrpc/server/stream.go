@@ -0,0 +1,322 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+const (
+	// streamChunkSize is the most body sent in a single message.
+	streamChunkSize = 32 * 1024
+
+	// streamHeader is sent by servers as soon as they start handling a
+	// HandleStream call, so clients can tell them apart from servers which
+	// don't implement it, before sending the body.
+	streamHeader = "httpgrpc-stream"
+)
+
+// HandleStream implements HTTPStreamServer.
+func (s Server) HandleStream(stream httpgrpc.HTTPStream_HandleStreamServer) error {
+	if err := stream.SendHeader(metadata.Pairs(streamHeader, "1")); err != nil {
+		return err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	body := &streamRequestBody{stream: stream, buf: first.Body, trailer: http.Header{}}
+	toHeader(first.Trailers, body.trailer)
+	req, err := s.newRequest(stream.Context(), first, body)
+	if err != nil {
+		return err
+	}
+	req.Trailer = body.trailer
+	req.ContentLength = -1
+	if n, err := strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64); err == nil {
+		req.ContentLength = n
+	}
+
+	w := &streamResponseWriter{server: &s, stream: stream, header: http.Header{}, accept: first.AcceptBodyEncodings}
+	s.handler.ServeHTTP(w, req)
+	return w.finish()
+}
+
+// streamRequestBody reads the request body from the messages following the
+// first, and the trailers from the last.
+type streamRequestBody struct {
+	stream  httpgrpc.HTTPStream_HandleStreamServer
+	buf     []byte
+	trailer http.Header
+	err     error
+}
+
+func (b *streamRequestBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		msg, err := b.stream.Recv()
+		if err != nil {
+			b.err = err
+			continue
+		}
+		b.buf = msg.Body
+		toHeader(msg.Trailers, b.trailer)
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+func (b *streamRequestBody) Close() error { return nil }
+
+// streamResponseWriter sends the code and headers with the first chunk of body,
+// and the rest of the body as it fills up chunks or is flushed. 5xx responses
+// are buffered and returned as errors, as Handle does. If the client accepts
+// it, the body is compressed as a single stream across the messages.
+type streamResponseWriter struct {
+	server     *Server
+	stream     httpgrpc.HTTPStream_HandleStreamServer
+	header     http.Header
+	code       int
+	buf        bytes.Buffer
+	accept     []string
+	enc        *bodyEncoder
+	sentHeader bool
+	err        error
+}
+
+func (w *streamResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *streamResponseWriter) isError() bool {
+	return w.code/100 == 5
+}
+
+func (w *streamResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(p)
+	if !w.isError() && w.buf.Len() >= streamChunkSize {
+		w.send(false)
+	}
+	return len(p), w.err
+}
+
+// Flush implements http.Flusher.
+func (w *streamResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if !w.isError() {
+		w.send(false)
+	}
+}
+
+// send sends what has been written so far, which is the last of the body if
+// final.
+func (w *streamResponseWriter) send(final bool) {
+	body := w.buf.Bytes()
+	defer w.buf.Reset()
+	if !w.sentHeader {
+		// Small bodies aren't worth compressing, but we can only tell if it's
+		// all been written.
+		if c := chooseCompressor(w.accept, w.header); c != nil && (!final || len(body) >= compressionMinSize) {
+			w.enc = newBodyEncoder(c, w.server)
+		}
+	}
+	if w.enc != nil {
+		if body, w.err = w.enc.encode(body, final); w.err != nil {
+			return
+		}
+	}
+	for w.err == nil && (!w.sentHeader || len(body) > 0) {
+		n := len(body)
+		if n > streamChunkSize {
+			n = streamChunkSize
+		}
+		msg := &httpgrpc.HTTPResponse{Body: body[:n]}
+		if !w.sentHeader {
+			msg.Code = int32(w.code)
+			msg.Headers, _ = splitTrailers(w.header)
+			if w.enc != nil {
+				msg.BodyEncoding = w.enc.name
+			}
+			w.sentHeader = true
+		}
+		w.err = w.stream.Send(msg)
+		body = body[n:]
+	}
+}
+
+func (w *streamResponseWriter) finish() error {
+	w.WriteHeader(http.StatusOK)
+	headers, trailers := splitTrailers(w.header)
+	if w.isError() {
+		return httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+			Code:     int32(w.code),
+			Headers:  headers,
+			Body:     w.buf.Bytes(),
+			Trailers: trailers,
+		})
+	}
+	w.send(true)
+	if w.err == nil && len(trailers) > 0 {
+		w.err = w.stream.Send(&httpgrpc.HTTPResponse{Trailers: trailers})
+	}
+	return w.err
+}
+
+// serveStream forwards the request over HandleStream. It returns false if the
+// server doesn't support it, without having read the request body. The error
+// is set if the response body failed after its status code had been written.
+func (c *Client) serveStream(w http.ResponseWriter, r *http.Request) (bool, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stream, err := c.stream.HandleStream(ctx)
+	if err != nil {
+		WriteError(w, err)
+		return true, nil
+	}
+	req := newHTTPRequest(r)
+	req.AcceptBodyEncodings = c.acceptBodyEncodings(r)
+	// If this fails, the stream has ended and Recv will return why.
+	_ = stream.Send(req)
+
+	md, err := stream.Header()
+	if err != nil {
+		WriteError(w, err)
+		return true, nil
+	}
+	if len(md.Get(streamHeader)) == 0 {
+		_, err := stream.Recv()
+		if status.Code(err) == codes.Unimplemented {
+			atomic.StoreInt32(&c.streamUnsupported, 1)
+			return false, nil
+		}
+		WriteError(w, err)
+		return true, nil
+	}
+
+	// Send the body concurrently, so the response can stream back as soon as
+	// the server starts writing it. The body can't be read once ServeHTTP
+	// returns, so wait for this to finish, which cancelling ctx and closing the
+	// body hurry along.
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		if r.Body == nil {
+			stream.CloseSend()
+			return
+		}
+		buf := make([]byte, streamChunkSize)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				if stream.Send(&httpgrpc.HTTPRequest{Body: buf[:n]}) != nil {
+					return
+				}
+			}
+			if err == io.EOF {
+				// Trailers are only set once the body has been read.
+				if len(r.Trailer) > 0 && stream.Send(&httpgrpc.HTTPRequest{Trailers: fromHeader(r.Trailer)}) != nil {
+					return
+				}
+				stream.CloseSend()
+				return
+			} else if err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	resp, err := stream.Recv()
+	if err != nil {
+		WriteError(w, err)
+		return true, nil
+	}
+	toHeader(resp.Headers, w.Header())
+	body := &streamResponseBody{stream: stream, buf: resp.Body, trailers: resp.Trailers}
+	decode := resp.BodyEncoding != "" && !passThroughEncoding(r, resp.BodyEncoding, w.Header())
+	w.WriteHeader(int(resp.Code))
+
+	var reader io.Reader = body
+	if decode {
+		if reader, err = decompressBody(resp.BodyEncoding, body); err != nil {
+			return true, err
+		}
+	}
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return true, nil
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return true, err
+		}
+	}
+	writeTrailers(w, body.trailers)
+	return true, nil
+}
+
+// streamResponseBody reads the response body from the messages following the
+// first, collecting their trailers.
+type streamResponseBody struct {
+	stream   httpgrpc.HTTPStream_HandleStreamClient
+	buf      []byte
+	trailers []*httpgrpc.Header
+	err      error
+}
+
+func (b *streamResponseBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		msg, err := b.stream.Recv()
+		if err != nil {
+			b.err = err
+			continue
+		}
+		b.buf = msg.Body
+		b.trailers = append(b.trailers, msg.Trailers...)
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
@@ -155,12 +155,9 @@ func CollectedRequest(ctx context.Context, method string, col Collector, toStatu
 	}
 	sp, newCtx := opentracing.StartSpanFromContext(ctx, method)
 	ext.SpanKindRPCClient.Set(sp)
-	if userID, err := user.ExtractUserID(ctx); err == nil {
-		sp.SetTag("user", userID)
-	}
-	if orgID, err := user.ExtractOrgID(ctx); err == nil {
-		sp.SetTag("organization", orgID)
-	}
+	userID, _ := user.ExtractUserID(ctx)
+	orgID, _ := user.ExtractOrgID(ctx)
+	user.TagSpan(sp, orgID, userID, false)
 
 	start := time.Now()
 	col.Before(newCtx, method, start)
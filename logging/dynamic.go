@@ -0,0 +1,334 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ComponentField is the field naming the part of a program a log entry
+	// comes from, as in logger.WithField(ComponentField, "grpc"). DynamicLevel
+	// overrides can apply to it.
+	ComponentField = "component"
+	// OrgIDField is the field user.LogWith puts the org ID in. DynamicLevel
+	// overrides can apply to it.
+	OrgIDField = "orgID"
+)
+
+// DynamicLevel is a Level which can be changed while loggers made with it are
+// running. They consult it on every call.
+//
+// It can be overridden for log entries from a component, or for an org, as
+// identified by their ComponentField and OrgIDField. An override for the org
+// takes precedence over one for the component.
+type DynamicLevel struct {
+	// The logrus level of the current level, so it can be checked without
+	// taking the lock.
+	current uint32
+	// A copy of the logrus levels of the overrides, replaced whenever they
+	// change, for the same reason.
+	overrideLevels atomic.Value // map[overrideKey]logrus.Level
+
+	mtx       sync.Mutex
+	level     Level
+	base      Level // What the level reverts to.
+	revertAt  time.Time
+	timer     *time.Timer
+	overrides map[overrideKey]*override
+}
+
+type overrideKey struct {
+	field, value string
+}
+
+type override struct {
+	level    Level
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+// LevelOverride is a level which applies instead of a DynamicLevel's own to
+// log entries with the given value of Field, ComponentField or OrgIDField.
+type LevelOverride struct {
+	Field    string
+	Value    string
+	Level    Level
+	RevertAt time.Time // The zero time if it won't.
+}
+
+// LevelOverrides configures DynamicLevel overrides.
+type LevelOverrides struct {
+	Components map[string]Level `yaml:"components"`
+	Orgs       map[string]Level `yaml:"orgs"`
+}
+
+// NewDynamicLevel makes a new DynamicLevel, starting at l.
+func NewDynamicLevel(l Level) *DynamicLevel {
+	return &DynamicLevel{
+		current:   uint32(l.Logrus),
+		level:     l,
+		base:      l,
+		overrides: map[overrideKey]*override{},
+	}
+}
+
+// Level returns the current level.
+func (d *DynamicLevel) Level() Level {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.level
+}
+
+// RevertAt returns when the level will revert, or the zero time if it won't.
+func (d *DynamicLevel) RevertAt() time.Time {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.revertAt
+}
+
+// Set changes the level. If ttl is positive the level reverts after it, to
+// the last level set without one; otherwise it stays.
+func (d *DynamicLevel) Set(l Level, ttl time.Duration) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.revertAt = time.Time{}
+	if ttl > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			d.mtx.Lock()
+			defer d.mtx.Unlock()
+			// Ignore a timer which fired while being replaced.
+			if d.timer == timer {
+				d.set(d.base)
+				d.timer = nil
+				d.revertAt = time.Time{}
+			}
+		})
+		d.timer = timer
+		d.revertAt = time.Now().Add(ttl)
+	} else {
+		d.base = l
+	}
+	d.set(l)
+}
+
+func (d *DynamicLevel) set(l Level) {
+	d.level = l
+	atomic.StoreUint32(&d.current, uint32(l.Logrus))
+}
+
+// SetOverride makes log entries whose field has the given value log at l,
+// instead of the current level. field must be ComponentField or OrgIDField.
+// If ttl is positive the override is removed after it; otherwise it stays.
+func (d *DynamicLevel) SetOverride(field, value string, l Level, ttl time.Duration) error {
+	if field != ComponentField && field != OrgIDField {
+		return fmt.Errorf("can't override the level by field %q", field)
+	}
+	key := overrideKey{field: field, value: value}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.clearOverride(key)
+	o := &override{level: l}
+	if ttl > 0 {
+		o.revertAt = time.Now().Add(ttl)
+		o.timer = time.AfterFunc(ttl, func() {
+			d.mtx.Lock()
+			defer d.mtx.Unlock()
+			// Ignore a timer which fired while being replaced.
+			if d.overrides[key] == o {
+				d.clearOverride(key)
+				d.publishOverrides()
+			}
+		})
+	}
+	d.overrides[key] = o
+	d.publishOverrides()
+	return nil
+}
+
+// SetOverrides sets each of the overrides in o, without a TTL.
+func (d *DynamicLevel) SetOverrides(o LevelOverrides) {
+	for component, l := range o.Components {
+		_ = d.SetOverride(ComponentField, component, l, 0)
+	}
+	for orgID, l := range o.Orgs {
+		_ = d.SetOverride(OrgIDField, orgID, l, 0)
+	}
+}
+
+// ClearOverride removes the override for the given field value, if any.
+func (d *DynamicLevel) ClearOverride(field, value string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.clearOverride(overrideKey{field: field, value: value})
+	d.publishOverrides()
+}
+
+// Overrides returns the current overrides, sorted by field and value.
+func (d *DynamicLevel) Overrides() []LevelOverride {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	overrides := make([]LevelOverride, 0, len(d.overrides))
+	for key, o := range d.overrides {
+		overrides = append(overrides, LevelOverride{Field: key.field, Value: key.value, Level: o.level, RevertAt: o.revertAt})
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Field != overrides[j].Field {
+			return overrides[i].Field < overrides[j].Field
+		}
+		return overrides[i].Value < overrides[j].Value
+	})
+	return overrides
+}
+
+func (d *DynamicLevel) clearOverride(key overrideKey) {
+	if o, ok := d.overrides[key]; ok {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+		delete(d.overrides, key)
+	}
+}
+
+func (d *DynamicLevel) publishOverrides() {
+	levels := make(map[overrideKey]logrus.Level, len(d.overrides))
+	for key, o := range d.overrides {
+		levels[key] = o.level.Logrus
+	}
+	d.overrideLevels.Store(levels)
+}
+
+// enabled returns true if entries at level l, from the given component and
+// org if known, should be logged.
+func (d *DynamicLevel) enabled(l logrus.Level, component, orgID string) bool {
+	allowed := logrus.Level(atomic.LoadUint32(&d.current))
+	if component != "" || orgID != "" {
+		levels, _ := d.overrideLevels.Load().(map[overrideKey]logrus.Level)
+		if ol, ok := levels[overrideKey{field: OrgIDField, value: orgID}]; ok && orgID != "" {
+			allowed = ol
+		} else if ol, ok := levels[overrideKey{field: ComponentField, value: component}]; ok && component != "" {
+			allowed = ol
+		}
+	}
+	return l <= allowed
+}
+
+// Dynamic wraps a logger to only log at the levels a DynamicLevel currently
+// allows. The logger itself should allow all of them.
+func Dynamic(level *DynamicLevel, logger Interface) Interface {
+	return dynamic{level: level, next: logger}
+}
+
+// NewLogrusFormatDynamic makes a new Interface backed by a logrus logger, with
+// a level which can be changed.
+// format can be "json" or defaults to logfmt
+func NewLogrusFormatDynamic(level *DynamicLevel, f Format) Interface {
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+	logger.Formatter = f.Logrus
+	return Dynamic(level, Logrus(logger))
+}
+
+// NewGoKitFormatDynamic makes a new Interface backed by a GoKit logger, with a
+// level which can be changed.
+// format can be "json" or defaults to logfmt
+func NewGoKitFormatDynamic(level *DynamicLevel, f Format) Interface {
+	// Dynamic takes the place of the level filter, so the caller is as deep.
+	logger := log.With(newGoKitLogger(f), "ts", log.DefaultTimestampUTC, "caller", log.Caller(5))
+	return Dynamic(level, gokit{logger})
+}
+
+// NewLogrusDynamic makes a new Interface backed by a logrus logger, with a
+// level which can be changed.
+func NewLogrusDynamic(level *DynamicLevel) Interface {
+	return NewLogrusFormatDynamic(level, Format{Logrus: &logrus.TextFormatter{}})
+}
+
+type dynamic struct {
+	level     *DynamicLevel
+	next      Interface
+	component string
+	orgID     string
+}
+
+func (d dynamic) enabled(l logrus.Level) bool {
+	return d.level.enabled(l, d.component, d.orgID)
+}
+
+// note records the field if overrides can apply to it.
+func (d *dynamic) note(key string, value interface{}) {
+	switch key {
+	case ComponentField:
+		d.component = fmt.Sprint(value)
+	case OrgIDField:
+		d.orgID = fmt.Sprint(value)
+	}
+}
+
+func (d dynamic) Debugf(format string, args ...interface{}) {
+	if d.enabled(logrus.DebugLevel) {
+		d.next.Debugf(format, args...)
+	}
+}
+func (d dynamic) Debugln(args ...interface{}) {
+	if d.enabled(logrus.DebugLevel) {
+		d.next.Debugln(args...)
+	}
+}
+
+func (d dynamic) Infof(format string, args ...interface{}) {
+	if d.enabled(logrus.InfoLevel) {
+		d.next.Infof(format, args...)
+	}
+}
+func (d dynamic) Infoln(args ...interface{}) {
+	if d.enabled(logrus.InfoLevel) {
+		d.next.Infoln(args...)
+	}
+}
+
+func (d dynamic) Warnf(format string, args ...interface{}) {
+	if d.enabled(logrus.WarnLevel) {
+		d.next.Warnf(format, args...)
+	}
+}
+func (d dynamic) Warnln(args ...interface{}) {
+	if d.enabled(logrus.WarnLevel) {
+		d.next.Warnln(args...)
+	}
+}
+
+func (d dynamic) Errorf(format string, args ...interface{}) {
+	if d.enabled(logrus.ErrorLevel) {
+		d.next.Errorf(format, args...)
+	}
+}
+func (d dynamic) Errorln(args ...interface{}) {
+	if d.enabled(logrus.ErrorLevel) {
+		d.next.Errorln(args...)
+	}
+}
+
+func (d dynamic) WithField(key string, value interface{}) Interface {
+	d.note(key, value)
+	d.next = d.next.WithField(key, value)
+	return d
+}
+
+func (d dynamic) WithFields(fields Fields) Interface {
+	for key, value := range fields {
+		d.note(key, value)
+	}
+	d.next = d.next.WithFields(fields)
+	return d
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDynamicLevel(t *testing.T) {
+	var info, debug, errorLevel Level
+	require.NoError(t, info.Set("info"))
+	require.NoError(t, debug.Set("debug"))
+	require.NoError(t, errorLevel.Set("error"))
+
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buf
+	logrusLogger.Level = logrus.DebugLevel
+	logrusLogger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	level := NewDynamicLevel(info)
+	logger := Dynamic(level, Logrus(logrusLogger)).WithField("a", 1)
+
+	logger.Debugf("hidden")
+	logger.Infof("shown")
+	level.Set(errorLevel, 0)
+	logger.Warnln("hidden")
+	level.Set(debug, time.Hour)
+	logger.Debugln("shown")
+	require.Equal(t, "level=info msg=shown a=1\nlevel=debug msg=shown a=1\n", buf.String())
+	require.False(t, level.RevertAt().IsZero())
+
+	// Setting a level again replaces the TTL.
+	level.Set(debug, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		l := level.Level()
+		return l.String() == "error"
+	}, time.Second, time.Millisecond)
+	require.True(t, level.RevertAt().IsZero())
+}
+
+func TestDynamicGoKitCaller(t *testing.T) {
+	var buf bytes.Buffer
+	var debug Level
+	require.NoError(t, debug.Set("debug"))
+	logger := Dynamic(NewDynamicLevel(debug), gokit{log.With(log.NewLogfmtLogger(&buf), "caller", log.Caller(5))})
+	logger.Infof("hello")
+	require.Contains(t, buf.String(), "caller=dynamic_test.go:")
+}
+
+func TestDynamicLevelOverrides(t *testing.T) {
+	var overrides LevelOverrides
+	require.NoError(t, yaml.Unmarshal([]byte("components:\n  grpc: debug\norgs:\n  quiet: error\n"), &overrides))
+
+	var warn, info Level
+	require.NoError(t, warn.Set("warn"))
+	require.NoError(t, info.Set("info"))
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buf
+	logrusLogger.Level = logrus.DebugLevel
+	logrusLogger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	level := NewDynamicLevel(warn)
+	level.SetOverrides(overrides)
+	logger := Dynamic(level, Logrus(logrusLogger))
+
+	grpcLogger := logger.WithField(ComponentField, "grpc")
+	logger.Infof("hidden")
+	grpcLogger.Debugf("component")
+	// The org's override takes precedence over the component's.
+	grpcLogger.WithFields(Fields{OrgIDField: "quiet"}).Warnf("hidden")
+	grpcLogger.WithField(OrgIDField, "other").Debugf("other org")
+	require.Equal(t, "level=debug msg=component component=grpc\nlevel=debug msg=\"other org\" component=grpc orgID=other\n", buf.String())
+	buf.Reset()
+
+	require.Error(t, level.SetOverride("method", "x", info, 0))
+	require.NoError(t, level.SetOverride(OrgIDField, "quiet", info, 10*time.Millisecond))
+	overridden := level.Overrides()
+	require.Len(t, overridden, 2)
+	require.Equal(t, ComponentField, overridden[0].Field)
+	require.Equal(t, "grpc", overridden[0].Value)
+	require.Equal(t, "debug", overridden[0].Level.String())
+	require.True(t, overridden[0].RevertAt.IsZero())
+	require.Equal(t, OrgIDField, overridden[1].Field)
+	require.False(t, overridden[1].RevertAt.IsZero())
+	require.Eventually(t, func() bool {
+		return len(level.Overrides()) == 1
+	}, time.Second, time.Millisecond)
+
+	level.ClearOverride(ComponentField, "grpc")
+	require.Empty(t, level.Overrides())
+	grpcLogger.Debugf("hidden")
+	require.Empty(t, buf.String())
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"flag"
+	"os"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -10,19 +11,27 @@ import (
 // Format is a settable identifier for the output format of logs
 type Format struct {
 	s      string
+	isSet  bool
 	Logrus logrus.Formatter
 }
 
 // RegisterFlags adds the log format flag to the provided flagset.
 func (f *Format) RegisterFlags(fs *flag.FlagSet) {
 	f.Set("logfmt")
-	fs.Var(f, "log.format", "Output log messages in the given format. Valid formats: [logfmt, json]")
+	f.isSet = false
+	fs.Var(f, "log.format", "Output log messages in the given format. Valid formats: [logfmt, json, console]")
 }
 
 func (f Format) String() string {
 	return f.s
 }
 
+// IsSet returns true if the format has been set, rather than left as the
+// default from RegisterFlags.
+func (f Format) IsSet() bool {
+	return f.isSet
+}
+
 // UnmarshalYAML implements yaml.Unmarshaler.
 func (f *Format) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var format string
@@ -41,12 +50,15 @@ func (f Format) MarshalYAML() (interface{}, error) {
 func (f *Format) Set(s string) error {
 	switch s {
 	case "logfmt":
-		f.Logrus = &logrus.JSONFormatter{}
+		f.Logrus = LogfmtFormatter{}
 	case "json":
 		f.Logrus = &logrus.JSONFormatter{}
+	case "console":
+		f.Logrus = ConsoleFormatter{Colors: os.Getenv("NO_COLOR") == ""}
 	default:
 		return errors.Errorf("unrecognized log format %q", s)
 	}
 	f.s = s
+	f.isSet = true
 	return nil
 }
@@ -11,13 +11,34 @@ import (
 // NewGoKitFormat creates a new Interface backed by a GoKit logger
 // format can be "json" or defaults to logfmt
 func NewGoKitFormat(l Level, f Format) Interface {
-	var logger log.Logger
+	return addStandardFields(newGoKitLogger(f), l)
+}
+
+func newGoKitLogger(f Format) log.Logger {
 	if f.s == "json" {
-		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
-	} else {
-		logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+		return lastCaller{log.NewJSONLogger(log.NewSyncWriter(os.Stderr))}
+	}
+	return lastCaller{log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))}
+}
+
+// lastCaller logs only the last caller in keyvals, so a caller field set by
+// the slog handler replaces the one found by log.Caller.
+type lastCaller struct {
+	log.Logger
+}
+
+func (l lastCaller) Log(keyvals ...interface{}) error {
+	last := -1
+	for i := 0; i < len(keyvals)-1; i += 2 {
+		if keyvals[i] == "caller" {
+			if last >= 0 {
+				keyvals = append(keyvals[:last:last], keyvals[last+2:]...)
+				i -= 2
+			}
+			last = i
+		}
 	}
-	return addStandardFields(logger, l)
+	return l.Logger.Log(keyvals...)
 }
 
 // stand-alone for test purposes
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/mgutz/ansi"
+	"github.com/sirupsen/logrus"
+)
+
+// LogfmtFormatter formats logrus entries as logfmt, like the GoKit logger:
+// ts, level, caller and msg, then the fields sorted by key.
+type LogfmtFormatter struct{}
+
+// Format implements logrus.Formatter.
+func (LogfmtFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	keyvals := []interface{}{
+		"ts", e.Time.UTC().Format(time.RFC3339Nano),
+		"level", levelName(e.Level),
+		"caller", entryCaller(e),
+		"msg", e.Message,
+	}
+	for _, k := range sortedKeys(e.Data) {
+		keyvals = append(keyvals, k, e.Data[k])
+	}
+
+	var buf bytes.Buffer
+	enc := logfmt.NewEncoder(&buf)
+	if err := enc.EncodeKeyvals(keyvals...); err != nil {
+		return nil, err
+	}
+	if err := enc.EndRecord(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConsoleFormatter formats logrus entries for people to read, on one line
+// each: the time, level, caller and message, then the fields sorted by key.
+// If Colors is set, the level and field names are coloured.
+type ConsoleFormatter struct {
+	Colors bool
+}
+
+var levelColors = map[logrus.Level]string{
+	logrus.DebugLevel: ansi.ColorCode("black+h"),
+	logrus.InfoLevel:  ansi.ColorCode("cyan"),
+	logrus.WarnLevel:  ansi.ColorCode("yellow"),
+	logrus.ErrorLevel: ansi.ColorCode("red"),
+	logrus.FatalLevel: ansi.ColorCode("red+b"),
+	logrus.PanicLevel: ansi.ColorCode("red+b"),
+}
+
+// Format implements logrus.Formatter.
+func (f ConsoleFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	color, reset := "", ""
+	if f.Colors {
+		color, reset = levelColors[e.Level], ansi.Reset
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s%-5s%s %s %s",
+		e.Time.Format("15:04:05.000"), color, strings.ToUpper(levelName(e.Level)), reset, entryCaller(e), e.Message)
+	for _, k := range sortedKeys(e.Data) {
+		fmt.Fprintf(&buf, " %s%s%s=%s", color, k, reset, consoleValue(e.Data[k]))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// consoleValue formats v, quoting it if it would otherwise be hard to tell
+// where it ends.
+func consoleValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// levelName returns the name of l, as the GoKit logger would log it.
+func levelName(l logrus.Level) string {
+	if l == logrus.WarnLevel {
+		return "warn"
+	}
+	return l.String()
+}
+
+// sortedKeys returns the keys of the fields, apart from the caller.
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "caller" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loggingDir is the directory of this package's source, to skip its frames
+// when looking for the caller.
+var loggingDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// entryCaller returns the caller field of e, as set by the slog handler, or
+// else finds the caller.
+func entryCaller(e *logrus.Entry) string {
+	if c, ok := e.Data["caller"].(string); ok {
+		return c
+	}
+	return caller()
+}
+
+// caller returns the file and line of whatever called the logger, skipping
+// logrus and the loggers in this package.
+func caller() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		inLogrus := strings.HasPrefix(frame.Function, "github.com/sirupsen/logrus.")
+		inLogging := filepath.Dir(frame.File) == loggingDir && !strings.HasSuffix(frame.File, "_test.go")
+		if !inLogrus && !inLogging {
+			return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
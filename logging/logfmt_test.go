@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	entry := &logrus.Entry{
+		Time:    time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.FixedZone("", 3600)),
+		Level:   logrus.WarnLevel,
+		Message: "request failed",
+		Data:    logrus.Fields{"method": "GET", "err": errors.New("connection refused"), "duration": time.Second},
+	}
+	_, _, line, _ := runtime.Caller(0)
+	out, err := LogfmtFormatter{}.Format(entry)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf(`ts=2020-01-02T02:04:05.006Z level=warn caller=logfmt_test.go:%d msg="request failed" duration=1s err="connection refused" method=GET`+"\n", line+1), string(out))
+
+	_, _, line, _ = runtime.Caller(0)
+	out, err = ConsoleFormatter{}.Format(entry)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf(`03:04:05.006 WARN  logfmt_test.go:%d request failed duration=1s err="connection refused" method=GET`+"\n", line+1), string(out))
+}
+
+func TestLogfmtCaller(t *testing.T) {
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buf
+	logrusLogger.Level = logrus.DebugLevel
+	logrusLogger.Formatter = LogfmtFormatter{}
+	var info Level
+	require.NoError(t, info.Set("info"))
+
+	// The caller is found through the wrappers in this package.
+	logger := NewSampled(SamplingConfig{}, Dynamic(NewDynamicLevel(info), Logrus(logrusLogger)), nil)
+	_, _, line, _ := runtime.Caller(0)
+	logger.WithField("a", 1).Infof("hello")
+	require.Contains(t, buf.String(), fmt.Sprintf(" level=info caller=logfmt_test.go:%d msg=hello a=1\n", line+1))
+	require.True(t, strings.HasPrefix(buf.String(), "ts="))
+}
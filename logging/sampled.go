@@ -0,0 +1,326 @@
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// maxDedupKeys bounds how many recent messages are remembered for
+// deduplication. If there are more within the window, they are all forgotten.
+const maxDedupKeys = 10000
+
+// SamplingConfig configures a Sampled logger.
+type SamplingConfig struct {
+	DedupWindow    time.Duration `yaml:"dedup_window"`
+	RateLimit      float64       `yaml:"rate_limit"`
+	RateLimitBurst int           `yaml:"rate_limit_burst"`
+	SummaryPeriod  time.Duration `yaml:"summary_period"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *SamplingConfig) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.DedupWindow, "log.dedup-window", 0, "Drop log messages identical to one logged within this window. 0 to disable.")
+	f.Float64Var(&cfg.RateLimit, "log.rate-limit", 0, "Log messages per second allowed at each level, beyond which they are dropped. 0 to disable.")
+	f.IntVar(&cfg.RateLimitBurst, "log.rate-limit-burst", 100, "Number of log messages allowed at each level in a burst, when log.rate-limit is set.")
+	f.DurationVar(&cfg.SummaryPeriod, "log.suppressed-summary-period", 10*time.Second, "How often to log how many messages were dropped by log.dedup-window and log.rate-limit. 0 to disable.")
+}
+
+// Enabled returns true if messages might be dropped.
+func (cfg SamplingConfig) Enabled() bool {
+	return cfg.DedupWindow > 0 || cfg.RateLimit > 0
+}
+
+// NewSuppressedCounter makes a counter of log messages dropped by a Sampled
+// logger, as it expects.
+func NewSuppressedCounter(namespace string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_messages_suppressed_total",
+		Help:      "Number of log messages dropped as duplicates or to keep under the rate limit.",
+	}, []string{"level", "reason"})
+}
+
+var sampledLevels = []struct {
+	level logrus.Level
+	name  string
+}{
+	{logrus.DebugLevel, "debug"},
+	{logrus.InfoLevel, "info"},
+	{logrus.WarnLevel, "warn"},
+	{logrus.ErrorLevel, "error"},
+}
+
+// Sampled is an Interface which drops messages identical to one logged
+// within the dedup window, and messages beyond the rate limit at each level.
+// Every summary period it logs how many it dropped at each level, and it
+// counts them in the suppressed counter if that is not nil.
+//
+// Messages the wrapped logger would not log anyway are neither counted nor
+// formatted if it was made with Dynamic; otherwise it should log all levels.
+type Sampled struct {
+	sampledLogger
+
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSampled wraps logger, summarising what it drops until Stop is called.
+func NewSampled(cfg SamplingConfig, logger Interface, suppressed *prometheus.CounterVec) *Sampled {
+	s := &Sampled{
+		sampledLogger: sampledLogger{
+			sampler: &sampler{
+				cfg:        cfg,
+				suppressed: suppressed,
+				seen:       map[string]time.Time{},
+				buckets:    map[logrus.Level]*logBucket{},
+				counts:     map[logrus.Level]*suppressedCounts{},
+			},
+			next: logger,
+		},
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if cfg.SummaryPeriod > 0 {
+		go s.loop()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+func (s *Sampled) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.sampler.cfg.SummaryPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.summarise()
+		case <-s.quit:
+			s.summarise()
+			return
+		}
+	}
+}
+
+// summarise logs how many messages were dropped since it was last called.
+func (s *Sampled) summarise() {
+	counts := s.sampler.takeCounts()
+	for _, l := range sampledLevels {
+		c, ok := counts[l.level]
+		if !ok {
+			continue
+		}
+		logger := s.next.WithFields(Fields{"duplicates": c.duplicates, "rate_limited": c.rateLimited})
+		msg := fmt.Sprintf("%d %s log messages suppressed", c.duplicates+c.rateLimited, l.name)
+		switch l.level {
+		case logrus.DebugLevel:
+			logger.Debugln(msg)
+		case logrus.InfoLevel:
+			logger.Infoln(msg)
+		case logrus.WarnLevel:
+			logger.Warnln(msg)
+		case logrus.ErrorLevel:
+			logger.Errorln(msg)
+		}
+	}
+}
+
+// Stop summarising dropped messages, after a final summary. It can be called
+// more than once.
+func (s *Sampled) Stop() {
+	s.stopOnce.Do(func() { close(s.quit) })
+	<-s.done
+}
+
+type suppressedCounts struct {
+	duplicates, rateLimited int
+}
+
+// logBucket is a token bucket of log messages.
+type logBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sampler is the state shared by a Sampled logger and those derived from it.
+type sampler struct {
+	cfg        SamplingConfig
+	suppressed *prometheus.CounterVec
+
+	mtx     sync.Mutex
+	seen    map[string]time.Time // When each message was last logged.
+	buckets map[logrus.Level]*logBucket
+	counts  map[logrus.Level]*suppressedCounts
+}
+
+// allow returns true if the message with the given key should be logged.
+func (s *sampler) allow(level logrus.Level, name, key string) bool {
+	now := mtime.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	dedup := s.cfg.DedupWindow > 0
+	if dedup {
+		if last, ok := s.seen[key]; ok && now.Sub(last) < s.cfg.DedupWindow {
+			s.suppress(level, name, "duplicate")
+			return false
+		}
+	}
+	if s.cfg.RateLimit > 0 && !s.take(level, now) {
+		s.suppress(level, name, "rate_limited")
+		return false
+	}
+	if dedup {
+		if len(s.seen) >= maxDedupKeys {
+			s.forget(now)
+		}
+		s.seen[key] = now
+	}
+	return true
+}
+
+func (s *sampler) take(level logrus.Level, now time.Time) bool {
+	burst := math.Max(float64(s.cfg.RateLimitBurst), 1)
+	b, ok := s.buckets[level]
+	if !ok {
+		b = &logBucket{tokens: burst, last: now}
+		s.buckets[level] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*s.cfg.RateLimit)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// forget messages logged before the dedup window, or all of them if that
+// doesn't make room.
+func (s *sampler) forget(now time.Time) {
+	for key, last := range s.seen {
+		if now.Sub(last) >= s.cfg.DedupWindow {
+			delete(s.seen, key)
+		}
+	}
+	if len(s.seen) >= maxDedupKeys {
+		s.seen = map[string]time.Time{}
+	}
+}
+
+func (s *sampler) suppress(level logrus.Level, name, reason string) {
+	c, ok := s.counts[level]
+	if !ok {
+		c = &suppressedCounts{}
+		s.counts[level] = c
+	}
+	if reason == "duplicate" {
+		c.duplicates++
+	} else {
+		c.rateLimited++
+	}
+	if s.suppressed != nil {
+		s.suppressed.WithLabelValues(name, reason).Inc()
+	}
+}
+
+func (s *sampler) takeCounts() map[logrus.Level]*suppressedCounts {
+	now := mtime.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	counts := s.counts
+	s.counts = map[logrus.Level]*suppressedCounts{}
+	if s.cfg.DedupWindow > 0 {
+		s.forget(now)
+	}
+	return counts
+}
+
+type sampledLogger struct {
+	*sampler
+	next Interface
+	// The fields added to next, as part of the key of each message for
+	// deduplication.
+	fields string
+}
+
+// log logs msg with logf if it should be. The message is only formatted if
+// next would log it.
+func (s sampledLogger) log(level logrus.Level, name string, format fmt.Stringer, logf func(args ...interface{})) {
+	if d, ok := s.next.(dynamic); ok && !d.enabled(level) {
+		return
+	}
+	msg := format.String()
+	if s.allow(level, name, name+"\x00"+s.fields+"\x00"+msg) {
+		logf(msg)
+	}
+}
+
+// Helper to defer sprintln until it is needed.
+type sprintln struct {
+	args []interface{}
+}
+
+func (s *sprintln) String() string {
+	return strings.TrimSuffix(fmt.Sprintln(s.args...), "\n")
+}
+
+func (s sampledLogger) Debugf(format string, args ...interface{}) {
+	s.log(logrus.DebugLevel, "debug", &sprintf{format, args}, s.next.Debugln)
+}
+func (s sampledLogger) Debugln(args ...interface{}) {
+	s.log(logrus.DebugLevel, "debug", &sprintln{args}, s.next.Debugln)
+}
+
+func (s sampledLogger) Infof(format string, args ...interface{}) {
+	s.log(logrus.InfoLevel, "info", &sprintf{format, args}, s.next.Infoln)
+}
+func (s sampledLogger) Infoln(args ...interface{}) {
+	s.log(logrus.InfoLevel, "info", &sprintln{args}, s.next.Infoln)
+}
+
+func (s sampledLogger) Warnf(format string, args ...interface{}) {
+	s.log(logrus.WarnLevel, "warn", &sprintf{format, args}, s.next.Warnln)
+}
+func (s sampledLogger) Warnln(args ...interface{}) {
+	s.log(logrus.WarnLevel, "warn", &sprintln{args}, s.next.Warnln)
+}
+
+func (s sampledLogger) Errorf(format string, args ...interface{}) {
+	s.log(logrus.ErrorLevel, "error", &sprintf{format, args}, s.next.Errorln)
+}
+func (s sampledLogger) Errorln(args ...interface{}) {
+	s.log(logrus.ErrorLevel, "error", &sprintln{args}, s.next.Errorln)
+}
+
+func (s sampledLogger) WithField(key string, value interface{}) Interface {
+	return sampledLogger{
+		sampler: s.sampler,
+		next:    s.next.WithField(key, value),
+		fields:  fmt.Sprintf("%s%s=%v ", s.fields, key, value),
+	}
+}
+
+func (s sampledLogger) WithFields(fields Fields) Interface {
+	var b strings.Builder
+	b.WriteString(s.fields)
+	for _, k := range sortedKeys(fields) {
+		fmt.Fprintf(&b, "%s=%v ", k, fields[k])
+	}
+	return sampledLogger{
+		sampler: s.sampler,
+		next:    s.next.WithFields(fields),
+		fields:  b.String(),
+	}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+func TestSampled(t *testing.T) {
+	now := time.Unix(1000, 0)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buf
+	logrusLogger.Level = logrus.DebugLevel
+	logrusLogger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	var info Level
+	require.NoError(t, info.Set("info"))
+	suppressed := NewSuppressedCounter("")
+	logger := NewSampled(SamplingConfig{
+		DedupWindow:    time.Minute,
+		RateLimit:      1,
+		RateLimitBurst: 3,
+		SummaryPeriod:  time.Hour,
+	}, Dynamic(NewDynamicLevel(info), Logrus(logrusLogger)), suppressed)
+
+	// Debug is disabled, so isn't counted.
+	logger.Debugf("hidden")
+	logger.Warnf("failed %d", 1)
+	logger.Warnln("failed", 1)
+	// Different fields make a different message.
+	logger.WithField("a", 1).Warnf("failed 1")
+	// This uses up the burst of 3.
+	logger.Warnf("failed %d", 2)
+	logger.Errorf("failed 1")
+	require.Equal(t, `level=warning msg="failed 1"
+level=warning msg="failed 1" a=1
+level=warning msg="failed 2"
+level=error msg="failed 1"
+`, buf.String())
+	buf.Reset()
+
+	// A second later, another warning is allowed, but a duplicate isn't.
+	mtime.NowForce(now.Add(time.Second))
+	logger.Warnln("failed 1")
+	logger.Warnln("failed 3")
+	logger.Warnln("failed 4")
+	require.Equal(t, "level=warning msg=\"failed 3\"\n", buf.String())
+	buf.Reset()
+
+	// After the window, the message is logged again.
+	mtime.NowForce(now.Add(time.Minute + 10*time.Second))
+	logger.Warnln("failed 1")
+	require.Equal(t, "level=warning msg=\"failed 1\"\n", buf.String())
+	buf.Reset()
+
+	logger.Stop()
+	logger.Stop() // Stopping twice has no effect.
+	require.Equal(t, "level=warning msg=\"3 warn log messages suppressed\" duplicates=2 rate_limited=1\n", buf.String())
+	require.Equal(t, float64(2), testutil.ToFloat64(suppressed.WithLabelValues("warn", "duplicate")))
+	require.Equal(t, float64(1), testutil.ToFloat64(suppressed.WithLabelValues("warn", "rate_limited")))
+	require.Equal(t, float64(0), testutil.ToFloat64(suppressed.WithLabelValues("debug", "rate_limited")))
+}
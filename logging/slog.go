@@ -0,0 +1,198 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewSlogFormat makes a new Interface backed by a slog logger.
+// format can be "json" or defaults to logfmt
+func NewSlogFormat(l Level, f Format) Interface {
+	opts := &slog.HandlerOptions{Level: SlogLevel(l)}
+	if f.s == "json" {
+		return NewSlog(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return NewSlog(slog.NewTextHandler(os.Stderr, opts))
+}
+
+// NewSlog makes a new Interface backed by a slog.Handler.
+func NewSlog(h slog.Handler) Interface {
+	return slogLogger{h}
+}
+
+// SlogLevel returns the slog.Level equivalent to l.
+func SlogLevel(l Level) slog.Level {
+	switch l.s {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+type slogLogger struct {
+	handler slog.Handler
+}
+
+// logf formats the message only if the handler wants it. It must be called
+// directly by the Interface method, so the caller's source position is right.
+func (s slogLogger) logf(level slog.Level, format string, args []interface{}) {
+	if !s.handler.Enabled(context.Background(), level) {
+		return
+	}
+	s.handle(level, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) logln(level slog.Level, args []interface{}) {
+	if !s.handler.Enabled(context.Background(), level) {
+		return
+	}
+	s.handle(level, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (s slogLogger) handle(level slog.Level, msg string) {
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:]) // Skip Callers, handle, logf and the Interface method.
+	_ = s.handler.Handle(context.Background(), slog.NewRecord(time.Now(), level, msg, pcs[0]))
+}
+
+func (s slogLogger) Debugf(format string, args ...interface{}) {
+	s.logf(slog.LevelDebug, format, args)
+}
+func (s slogLogger) Debugln(args ...interface{}) {
+	s.logln(slog.LevelDebug, args)
+}
+
+func (s slogLogger) Infof(format string, args ...interface{}) {
+	s.logf(slog.LevelInfo, format, args)
+}
+func (s slogLogger) Infoln(args ...interface{}) {
+	s.logln(slog.LevelInfo, args)
+}
+
+func (s slogLogger) Warnf(format string, args ...interface{}) {
+	s.logf(slog.LevelWarn, format, args)
+}
+func (s slogLogger) Warnln(args ...interface{}) {
+	s.logln(slog.LevelWarn, args)
+}
+
+func (s slogLogger) Errorf(format string, args ...interface{}) {
+	s.logf(slog.LevelError, format, args)
+}
+func (s slogLogger) Errorln(args ...interface{}) {
+	s.logln(slog.LevelError, args)
+}
+
+func (s slogLogger) WithField(key string, value interface{}) Interface {
+	return slogLogger{s.handler.WithAttrs([]slog.Attr{slog.Any(key, value)})}
+}
+
+func (s slogLogger) WithFields(fields Fields) Interface {
+	// Sort the fields, as the handler keeps them in order.
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+	return slogLogger{s.handler.WithAttrs(attrs)}
+}
+
+// NewSlogHandler makes a slog.Handler which logs to an Interface, handling
+// records at or above the given Level. Attributes are passed on as fields,
+// prefixed with the groups they are in, as in "group.key", and the source
+// position of the slog call as the caller field.
+func NewSlogHandler(logger Interface, l Level) slog.Handler {
+	return &slogHandler{logger: logger, level: SlogLevel(l)}
+}
+
+type slogHandler struct {
+	logger Interface
+	level  slog.Level
+	prefix string
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(Fields, r.NumAttrs()+1)
+	r.Attrs(func(a slog.Attr) bool {
+		addField(fields, h.prefix, a)
+		return true
+	})
+	// The logger would find this handler as its caller, so pass on where slog
+	// was called instead.
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		fields["caller"] = filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+	}
+	logger := h.logger
+	if len(fields) > 0 {
+		logger = logger.WithFields(fields)
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		logger.Errorln(r.Message)
+	case r.Level >= slog.LevelWarn:
+		logger.Warnln(r.Message)
+	case r.Level >= slog.LevelInfo:
+		logger.Infoln(r.Message)
+	default:
+		logger.Debugln(r.Message)
+	}
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(Fields, len(attrs))
+	for _, a := range attrs {
+		addField(fields, h.prefix, a)
+	}
+	return &slogHandler{logger: h.logger.WithFields(fields), level: h.level, prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, level: h.level, prefix: h.prefix + name + "."}
+}
+
+// addField adds a to fields, flattening groups.
+func addField(fields Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[prefix+a.Key] = a.Value.Any()
+		return
+	}
+	// Groups without a key are inlined.
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		addField(fields, prefix, ga)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlog(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey:
+				return slog.Attr{}
+			case slog.SourceKey:
+				source := a.Value.Any().(*slog.Source)
+				return slog.String(a.Key, filepath.Base(source.File))
+			}
+			return a
+		},
+	}))
+
+	logger.Debugf("hidden %d", 1)
+	logger.WithField("a", 1).WithFields(Fields{"c": "3", "b": 2}).Infof("hello %s", "world")
+	logger.Warnln("two", "words")
+	require.Equal(t, `level=INFO source=slog_test.go msg="hello world" a=1 b=2 c=3
+level=WARN source=slog_test.go msg="two words"
+`, buf.String())
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var lvl Level
+	require.NoError(t, lvl.Set("info"))
+	logger := GoKit(level.NewFilter(log.NewLogfmtLogger(&buf), lvl.Gokit))
+	slogger := slog.New(NewSlogHandler(logger, lvl))
+
+	slogger.Debug("hidden")
+	_, _, line, _ := runtime.Caller(0)
+	slogger.With("a", 1).WithGroup("g").Info("hello", "b", 2, slog.Group("h", "c", 3))
+	slogger.Error("failed")
+	lines := strings.Split(buf.String(), "\n")
+	require.Len(t, lines, 3)
+	// Fields are passed on in no particular order.
+	require.ElementsMatch(t, []string{"level=info", "msg=hello", "a=1", "g.b=2", "g.h.c=3", fmt.Sprintf("caller=slog_test.go:%d", line+1)}, strings.Fields(lines[0]))
+	require.Equal(t, fmt.Sprintf("level=error caller=slog_test.go:%d msg=failed", line+2), lines[1])
+}
+
+func TestSlogHandlerCaller(t *testing.T) {
+	var info Level
+	require.NoError(t, info.Set("info"))
+
+	// The caller slog reports replaces the one the logger would find.
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buf
+	logrusLogger.Formatter = LogfmtFormatter{}
+	_, _, line, _ := runtime.Caller(0)
+	slog.New(NewSlogHandler(Logrus(logrusLogger), info)).Info("hello")
+	require.Contains(t, buf.String(), fmt.Sprintf(" level=info caller=slog_test.go:%d msg=hello\n", line+1))
+	require.Equal(t, 1, strings.Count(buf.String(), "caller="))
+
+	buf.Reset()
+	logger := GoKit(log.With(lastCaller{log.NewLogfmtLogger(&buf)}, "caller", log.Caller(5)))
+	_, _, line, _ = runtime.Caller(0)
+	slog.New(NewSlogHandler(logger, info)).Info("hello")
+	require.Equal(t, fmt.Sprintf("level=info caller=slog_test.go:%d msg=hello\n", line+1), buf.String())
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Modes for ConcurrencyLimiter.
+const (
+	// ConcurrencyLimitFixed never changes the limit.
+	ConcurrencyLimitFixed = "fixed"
+	// ConcurrencyLimitAIMD increases the limit additively while latency is below
+	// a threshold, and decreases it multiplicatively when latency is above it.
+	ConcurrencyLimitAIMD = "aimd"
+	// ConcurrencyLimitGradient moves the limit by the ratio between the minimum
+	// and the current latency, shrinking it as queueing builds up.
+	ConcurrencyLimitGradient = "gradient"
+)
+
+const (
+	aimdBackoffRatio      = 0.9
+	gradientSmoothing     = 0.2
+	gradientMinRTTSamples = 1000
+)
+
+// ConcurrencyLimiter bounds the number of requests in flight. Depending on the
+// mode, the limit adapts to the latency observed for completed requests,
+// between 1 and the configured maximum.
+type ConcurrencyLimiter struct {
+	mode             string
+	max              float64
+	latencyThreshold time.Duration
+	limitGauge       prometheus.Gauge
+
+	mtx      sync.Mutex
+	inflight int
+	limit    float64
+	minRTT   time.Duration
+	samples  int
+}
+
+// NewConcurrencyLimiter makes a new ConcurrencyLimiter allowing up to max
+// requests in flight. latencyThreshold is only used in AIMD mode. The current
+// limit is reported in limitGauge, if not nil.
+func NewConcurrencyLimiter(mode string, max int, latencyThreshold time.Duration, limitGauge prometheus.Gauge) (*ConcurrencyLimiter, error) {
+	switch mode {
+	case ConcurrencyLimitFixed, ConcurrencyLimitAIMD, ConcurrencyLimitGradient:
+	default:
+		return nil, fmt.Errorf("unrecognized concurrency limit mode %q", mode)
+	}
+	if max <= 0 {
+		return nil, fmt.Errorf("concurrency limit must be > 0, got %d", max)
+	}
+	l := &ConcurrencyLimiter{
+		mode:             mode,
+		max:              float64(max),
+		latencyThreshold: latencyThreshold,
+		limitGauge:       limitGauge,
+		limit:            float64(max),
+	}
+	l.setLimit(l.limit)
+	return l, nil
+}
+
+// Limit returns the current limit.
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return int(l.limit)
+}
+
+// Acquire reserves a slot for a request. If the limit has been reached it
+// returns false; otherwise the returned func must be called when the request
+// completes.
+func (l *ConcurrencyLimiter) Acquire() (func(), bool) {
+	return l.acquire(true)
+}
+
+// AcquireStream reserves a slot for a long-lived request, like a gRPC stream,
+// as Acquire does. How long it lasts says nothing about latency, so it doesn't
+// change the limit.
+func (l *ConcurrencyLimiter) AcquireStream() (func(), bool) {
+	return l.acquire(false)
+}
+
+func (l *ConcurrencyLimiter) acquire(adapt bool) (func(), bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.inflight >= int(l.limit) {
+		return nil, false
+	}
+	l.inflight++
+
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(adapt, time.Since(start)) })
+	}, true
+}
+
+func (l *ConcurrencyLimiter) release(adapt bool, rtt time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.inflight--
+	if !adapt {
+		return
+	}
+
+	switch l.mode {
+	case ConcurrencyLimitAIMD:
+		if rtt > l.latencyThreshold {
+			l.setLimit(l.limit * aimdBackoffRatio)
+		} else {
+			l.setLimit(l.limit + 1/l.limit)
+		}
+
+	case ConcurrencyLimitGradient:
+		// Periodically forget the minimum, so the baseline can move up.
+		l.samples++
+		if l.minRTT == 0 || rtt < l.minRTT || l.samples >= gradientMinRTTSamples {
+			l.minRTT = rtt
+			l.samples = 0
+		}
+		if rtt <= 0 {
+			return
+		}
+		gradient := math.Max(0.5, math.Min(1, float64(l.minRTT)/float64(rtt)))
+		newLimit := l.limit*gradient + math.Sqrt(l.limit)
+		l.setLimit(l.limit*(1-gradientSmoothing) + newLimit*gradientSmoothing)
+	}
+}
+
+func (l *ConcurrencyLimiter) setLimit(limit float64) {
+	l.limit = math.Max(1, math.Min(l.max, limit))
+	if l.limitGauge != nil {
+		l.limitGauge.Set(math.Floor(l.limit))
+	}
+}
+
+// ConcurrencyLimit is a middleware which rejects requests with a 503 when the
+// Limiter is saturated. Requests for ExemptPaths, like health checks and
+// metrics, are never limited, and don't count towards the limit.
+type ConcurrencyLimit struct {
+	Limiter     *ConcurrencyLimiter
+	Rejected    prometheus.Counter
+	ExemptPaths []string
+}
+
+// Wrap implements Interface
+func (c ConcurrencyLimit) Wrap(next http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(c.ExemptPaths))
+	for _, p := range c.ExemptPaths {
+		exempt[path.Clean(p)] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exempt[path.Clean(r.URL.Path)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		release, ok := c.Limiter.Acquire()
+		if !ok {
+			c.Rejected.Inc()
+			http.Error(w, "too many requests in flight", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GRPCConcurrencyLimit rejects gRPC requests with Unavailable when the Limiter
+// is saturated. Requests for ExemptMethods, like health checks, are never
+// limited. Streams take a slot, but don't adapt the limit, unless they are for
+// one of the RequestStreams methods, whose streams each carry a single request
+// and last until it completes, like httpgrpc's HandleStream.
+type GRPCConcurrencyLimit struct {
+	Limiter        *ConcurrencyLimiter
+	Rejected       prometheus.Counter
+	ExemptMethods  []string
+	RequestStreams []string
+}
+
+// UnaryServerInterceptor limits unary requests.
+func (c GRPCConcurrencyLimit) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if contains(c.ExemptMethods, info.FullMethod) {
+		return handler(ctx, req)
+	}
+	release, ok := c.Limiter.Acquire()
+	if !ok {
+		c.Rejected.Inc()
+		return nil, status.Error(codes.Unavailable, "too many requests in flight")
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor limits streaming requests.
+func (c GRPCConcurrencyLimit) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if contains(c.ExemptMethods, info.FullMethod) {
+		return handler(srv, ss)
+	}
+	acquire := c.Limiter.AcquireStream
+	if contains(c.RequestStreams, info.FullMethod) {
+		acquire = c.Limiter.Acquire
+	}
+	release, ok := acquire()
+	if !ok {
+		c.Rejected.Inc()
+		return status.Error(codes.Unavailable, "too many requests in flight")
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
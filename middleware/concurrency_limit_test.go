@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimitHTTP(t *testing.T) {
+	limiter, err := NewConcurrencyLimiter(ConcurrencyLimitFixed, 1, 0, nil)
+	require.NoError(t, err)
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"})
+
+	started, unblock := make(chan struct{}), make(chan struct{})
+	handler := ConcurrencyLimit{Limiter: limiter, Rejected: rejected}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, 1.0, testutil.ToFloat64(rejected))
+
+	// Exempt paths are served anyway, however they are written.
+	exempt := ConcurrencyLimit{Limiter: limiter, Rejected: rejected, ExemptPaths: []string{"/api//ready"}}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, path := range []string{"/api/ready", "/api//ready", "/api/v1/../ready"} {
+		rec = httptest.NewRecorder()
+		exempt.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+
+	close(unblock)
+	<-done
+	_, ok := limiter.Acquire()
+	require.True(t, ok)
+}
+
+func TestConcurrencyLimitGRPC(t *testing.T) {
+	limiter, err := NewConcurrencyLimiter(ConcurrencyLimitFixed, 1, 0, nil)
+	require.NoError(t, err)
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"})
+	interceptor := GRPCConcurrencyLimit{Limiter: limiter, Rejected: rejected, ExemptMethods: []string{"Exempt"}}.UnaryServerInterceptor
+	info := &grpc.UnaryServerInfo{FullMethod: "Test"}
+
+	release, ok := limiter.Acquire()
+	require.True(t, ok)
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	// Exempt methods are served anyway.
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "Exempt"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	release()
+	release() // Releasing twice has no effect.
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "limit"})
+	limiter, err := NewConcurrencyLimiter(ConcurrencyLimitAIMD, 10, time.Millisecond, gauge)
+	require.NoError(t, err)
+	require.Equal(t, 10.0, testutil.ToFloat64(gauge))
+
+	// Slow requests shrink the limit.
+	for i := 0; i < 5; i++ {
+		release, ok := limiter.Acquire()
+		require.True(t, ok)
+		time.Sleep(2 * time.Millisecond)
+		release()
+	}
+	require.Equal(t, 5, limiter.Limit())
+	require.Equal(t, 5.0, testutil.ToFloat64(gauge))
+
+	// Fast requests grow it again, up to the maximum.
+	limiter.latencyThreshold = time.Hour
+	for i := 0; i < 100; i++ {
+		release, ok := limiter.Acquire()
+		require.True(t, ok)
+		release()
+	}
+	require.Equal(t, 10, limiter.Limit())
+}
+
+func TestConcurrencyLimiterStreams(t *testing.T) {
+	limiter, err := NewConcurrencyLimiter(ConcurrencyLimitAIMD, 10, time.Millisecond, nil)
+	require.NoError(t, err)
+	interceptor := GRPCConcurrencyLimit{
+		Limiter:        limiter,
+		Rejected:       prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"}),
+		RequestStreams: []string{"Request"},
+	}.StreamServerInterceptor
+	stream := func(method string) {
+		err := interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, stream grpc.ServerStream) error {
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Long-lived streams don't shrink the limit.
+	for i := 0; i < 5; i++ {
+		stream("Test")
+	}
+	require.Equal(t, 10, limiter.Limit())
+
+	// Streams which are a single request do.
+	for i := 0; i < 5; i++ {
+		stream("Request")
+	}
+	require.Equal(t, 5, limiter.Limit())
+}
+
+func TestConcurrencyLimiterGradient(t *testing.T) {
+	limiter, err := NewConcurrencyLimiter(ConcurrencyLimitGradient, 100, 0, nil)
+	require.NoError(t, err)
+
+	release, ok := limiter.Acquire()
+	require.True(t, ok)
+	release()
+	require.Equal(t, 100, limiter.Limit())
+
+	// Latency well above the minimum shrinks the limit.
+	for i := 0; i < 10; i++ {
+		release, ok := limiter.Acquire()
+		require.True(t, ok)
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}
+	require.Less(t, limiter.Limit(), 100)
+	require.GreaterOrEqual(t, limiter.Limit(), 1)
+}
+
+func TestNewConcurrencyLimiterErrors(t *testing.T) {
+	_, err := NewConcurrencyLimiter("bogus", 1, 0, nil)
+	require.EqualError(t, err, `unrecognized concurrency limit mode "bogus"`)
+	_, err = NewConcurrencyLimiter(ConcurrencyLimitFixed, 0, 0, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+// UnaryServerLegacyHTTPCodesInterceptor returns httpgrpc errors with their HTTP
+// status code as the gRPC code, for clients which still expect it.
+func UnaryServerLegacyHTTPCodesInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		err = httpgrpc.LegacyError(err)
+	}
+	return resp, err
+}
+
+// StreamServerLegacyHTTPCodesInterceptor returns httpgrpc errors with their
+// HTTP status code as the gRPC code, for clients which still expect it.
+func StreamServerLegacyHTTPCodesInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err != nil {
+		err = httpgrpc.LegacyError(err)
+	}
+	return err
+}
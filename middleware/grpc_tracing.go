@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/user"
+)
+
+var lowerOrgIDHeaderName = strings.ToLower(user.OrgIDHeaderName)
+
+// GRPCTenantTracer tags the span of each gRPC request, as started by the
+// OpenTracing interceptors, with the org ID in its metadata. If Baggage is
+// set, it is also put in the span's baggage, to be sent on to other services.
+// If FromBaggage is set, the metadata is set from the baggage when it doesn't
+// have the org ID, so ServerUserHeaderInterceptor finds it.
+type GRPCTenantTracer struct {
+	Baggage     bool
+	FromBaggage bool
+}
+
+// UnaryServerInterceptor tags the span of unary requests.
+func (t GRPCTenantTracer) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(t.tagSpan(ctx), req)
+}
+
+// StreamServerInterceptor tags the span of streaming requests.
+func (t GRPCTenantTracer) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, serverStream{
+		ctx:          t.tagSpan(ss.Context()),
+		ServerStream: ss,
+	})
+}
+
+func (t GRPCTenantTracer) tagSpan(ctx context.Context) context.Context {
+	sp := opentracing.SpanFromContext(ctx)
+	if sp == nil {
+		return ctx
+	}
+
+	orgID, _, _ := user.ExtractFromGRPCRequest(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get(lowerOrgIDHeaderName)) == 0 && t.FromBaggage {
+		if orgID = sp.BaggageItem(user.OrgIDBaggageItem); orgID != "" {
+			md = md.Copy()
+			md.Set(lowerOrgIDHeaderName, orgID)
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+	}
+	user.TagSpan(sp, orgID, "", t.Baggage)
+	return ctx
+}
+
+// UnaryServerRouteTagInterceptor tags the span of each request with its gRPC
+// method, for sampling.
+func UnaryServerRouteTagInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if sp := opentracing.SpanFromContext(ctx); sp != nil {
+		sp.SetTag(tracing.RouteTag, info.FullMethod)
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerRouteTagInterceptor tags the span of each streaming request with
+// its gRPC method, for sampling.
+func StreamServerRouteTagInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if sp := opentracing.SpanFromContext(ss.Context()); sp != nil {
+		sp.SetTag(tracing.RouteTag, info.FullMethod)
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/user"
+)
+
+func TestGRPCTenantTracer(t *testing.T) {
+	tracer := mocktracer.New()
+	interceptor := GRPCTenantTracer{Baggage: true, FromBaggage: true}.UnaryServerInterceptor
+	var orgID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		var err error
+		orgID, _, err = user.ExtractFromGRPCRequest(ctx)
+		return nil, err
+	}
+
+	// The org ID in the metadata is tagged and put in the baggage.
+	sp := tracer.StartSpan("test")
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(user.OrgIDHeaderName, "team1"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "team1", orgID)
+	require.Equal(t, "team1", sp.(*mocktracer.MockSpan).Tag(user.OrgIDSpanTag))
+	require.Equal(t, "team1", sp.BaggageItem(user.OrgIDBaggageItem))
+
+	// Without it, the org ID is recovered from the baggage.
+	sp = tracer.StartSpan("test")
+	sp.SetBaggageItem(user.OrgIDBaggageItem, "team2")
+	ctx = opentracing.ContextWithSpan(context.Background(), sp)
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "team2", orgID)
+	require.Equal(t, "team2", sp.(*mocktracer.MockSpan).Tag(user.OrgIDSpanTag))
+
+	// Unless that is disabled.
+	interceptor = GRPCTenantTracer{}.UnaryServerInterceptor
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.Equal(t, user.ErrNoOrgID, err)
+}
+
+func TestUnaryServerRouteTagInterceptor(t *testing.T) {
+	sp := mocktracer.New().StartSpan("test")
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+	_, err := UnaryServerRouteTagInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/httpgrpc.HTTP/Handle"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "/httpgrpc.HTTP/Handle", sp.(*mocktracer.MockSpan).Tag(tracing.RouteTag))
+}
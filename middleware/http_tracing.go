@@ -6,6 +6,9 @@ import (
 
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
+
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/user"
 )
 
 // Dummy dependency to enforce that we have a nethttp version newer
@@ -13,9 +16,16 @@ import (
 var _ = nethttp.MWURLTagFunc
 
 // Tracer is a middleware which traces incoming requests.
+//
+// Spans are tagged with the org and user IDs in the request headers. If
+// TenantBaggage is set, they are also put in the span's baggage, to be sent on
+// to other services. If TenantFromBaggage is set, the headers are set from the
+// baggage when the request doesn't have them.
 type Tracer struct {
-	RouteMatcher RouteMatcher
-	SourceIPs    *SourceIPExtractor
+	RouteMatcher      RouteMatcher
+	SourceIPs         *SourceIPExtractor
+	TenantBaggage     bool
+	TenantFromBaggage bool
 }
 
 // Wrap implements Interface
@@ -41,8 +51,34 @@ func (t Tracer) Wrap(next http.Handler) http.Handler {
 			if t.SourceIPs != nil {
 				sp.SetTag("sourceIPs", t.SourceIPs.Get(r))
 			}
+
+			orgID := t.tenantHeader(sp, r, user.OrgIDHeaderName, user.OrgIDBaggageItem)
+			userID := t.tenantHeader(sp, r, user.UserIDHeaderName, user.UserIDBaggageItem)
+			user.TagSpan(sp, orgID, userID, t.TenantBaggage)
+
+			// Tag the outermost span of the request in this process with its
+			// route, for sampling; for requests through httpgrpc, that is the
+			// gRPC one.
+			routeSpan := sp
+			if parent := opentracing.SpanFromContext(r.Context()); parent != nil {
+				routeSpan = parent
+			}
+			routeSpan.SetTag(tracing.RouteTag, getRouteName(t.RouteMatcher, r))
 		}),
 	}
 
 	return nethttp.Middleware(opentracing.GlobalTracer(), next, options...)
 }
+
+// tenantHeader returns the header, first setting it from the span's baggage
+// if it is missing and TenantFromBaggage is set. The handler sees the change,
+// as it gets a copy of the request sharing its headers.
+func (t Tracer) tenantHeader(sp opentracing.Span, r *http.Request, header, baggageItem string) string {
+	value := r.Header.Get(header)
+	if value == "" && t.TenantFromBaggage {
+		if value = sp.BaggageItem(baggageItem); value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+	return value
+}
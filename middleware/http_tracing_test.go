@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/tracing/tracingtest"
+	"github.com/weaveworks/common/user"
+)
+
+func TestTracerW3CTraceContext(t *testing.T) {
+	cfg := jaegercfg.Configuration{
+		ServiceName: "test",
+		Sampler:     &jaegercfg.SamplerConfig{Type: jaeger.SamplerTypeConst, Param: 1},
+	}
+	propagators := tracing.Propagators{tracing.PropagatorJaeger, tracing.PropagatorTraceContext, tracing.PropagatorBaggage}
+	tracer, closer, err := cfg.NewTracer(append(propagators.JaegerOptions(), jaegercfg.Reporter(jaeger.NewNullReporter()))...)
+	require.NoError(t, err)
+	defer closer.Close()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var traceID, org string
+	handler := Tracer{}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID, _ = tracing.ExtractTraceID(r.Context())
+		org = opentracing.SpanFromContext(r.Context()).BaggageItem("org")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	req.Header.Set("baggage", "org=team1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", traceID)
+	require.Equal(t, "team1", org)
+}
+
+func TestTracerTenant(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var orgID, orgBaggage string
+	handler := Tracer{TenantBaggage: true, TenantFromBaggage: true}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgID = r.Header.Get(user.OrgIDHeaderName)
+		orgBaggage = opentracing.SpanFromContext(r.Context()).BaggageItem(user.OrgIDBaggageItem)
+	}))
+
+	// The IDs in the headers are tagged and put in the baggage.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(user.OrgIDHeaderName, "team1")
+	req.Header.Set(user.UserIDHeaderName, "alice")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "team1", orgID)
+	require.Equal(t, "team1", orgBaggage)
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "team1", spans[0].Tag(user.OrgIDSpanTag))
+	require.Equal(t, "alice", spans[0].Tag(user.UserIDSpanTag))
+	tracer.Reset()
+
+	// Without the header, the org ID is recovered from the baggage.
+	parent := tracer.StartSpan("parent")
+	parent.SetBaggageItem(user.OrgIDBaggageItem, "team2")
+	req = httptest.NewRequest("GET", "/", nil)
+	require.NoError(t, tracer.Inject(parent.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header)))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "team2", orgID)
+	spans = tracer.FinishedSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "team2", spans[0].Tag(user.OrgIDSpanTag))
+	require.Nil(t, spans[0].Tag(user.UserIDSpanTag))
+}
+
+func TestTracerRouteTag(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	router := mux.NewRouter()
+	router.Path("/api/v1/push").Name("push")
+	handler := Tracer{RouteMatcher: router}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/push", nil))
+	tracingtest.RequireTag(t, recorder.Span(t, "HTTP GET - push"), tracing.RouteTag, "push")
+	recorder.Reset()
+
+	// Requests through httpgrpc tag the gRPC span, where sampling is decided.
+	parent := recorder.StartSpan("/httpgrpc.HTTP/Handle")
+	req := httptest.NewRequest("GET", "/api/v1/push", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(opentracing.ContextWithSpan(req.Context(), parent)))
+	parent.Finish()
+	tracingtest.RequireNoTag(t, recorder.Span(t, "HTTP GET - push"), tracing.RouteTag)
+	tracingtest.RequireTag(t, recorder.Span(t, "/httpgrpc.HTTP/Handle"), tracing.RouteTag, "push")
+}
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/common/logging"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/user"
+)
+
+// RateLimit is a token bucket limit: Rate requests per second on average, with
+// bursts of up to Burst requests. A Rate <= 0 means unlimited.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// RateLimitOverrides is the format of the per-tenant overrides file.
+type RateLimitOverrides struct {
+	Overrides map[string]RateLimit `yaml:"overrides"`
+}
+
+func (limit RateLimit) burst() float64 {
+	return math.Max(float64(limit.Burst), 1)
+}
+
+// RateLimiterConfig configures a TenantRateLimiter.
+type RateLimiterConfig struct {
+	MetricsNamespace string `yaml:"-"`
+
+	Default       RateLimit     `yaml:"default"`
+	OverridesFile string        `yaml:"overrides_file"`
+	ReloadPeriod  time.Duration `yaml:"reload_period"`
+}
+
+const (
+	// How long a tenant's bucket, and its metrics, are kept after its last
+	// request. Buckets which haven't refilled yet are kept longer.
+	bucketIdleTimeout = 10 * time.Minute
+	// How often to look for idle buckets.
+	evictInterval = time.Minute
+)
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *RateLimiterConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Float64Var(&cfg.Default.Rate, "rate-limit.rate", 0, "Requests per second allowed for each tenant, <=0 to disable.")
+	f.IntVar(&cfg.Default.Burst, "rate-limit.burst", 0, "Number of requests a tenant can make in a burst.")
+	f.StringVar(&cfg.OverridesFile, "rate-limit.overrides-file", "", "YAML file of per-tenant rate limits.")
+	f.DurationVar(&cfg.ReloadPeriod, "rate-limit.reload-period", 10*time.Second, "How often to reload the overrides file, 0 to disable.")
+}
+
+// TenantRateLimiter limits requests per tenant, as identified by the org ID
+// in the request context, so it must come after AuthenticateUser or
+// ServerUserHeaderInterceptor. Requests without an org ID are rejected. It can
+// be used both as HTTP middleware and as gRPC interceptors.
+type TenantRateLimiter struct {
+	cfg       RateLimiterConfig
+	log       logging.Interface
+	throttled *prometheus.CounterVec
+
+	mtx          sync.Mutex
+	overrides    map[string]RateLimit
+	buckets      map[string]*tokenBucket
+	lastEviction time.Time
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewTenantRateLimiter makes a new TenantRateLimiter, loading the overrides file
+// if set, and reloading it periodically until Stop is called.
+func NewTenantRateLimiter(cfg RateLimiterConfig, log logging.Interface, reg prometheus.Registerer) (*TenantRateLimiter, error) {
+	l := &TenantRateLimiter{
+		cfg: cfg,
+		log: log,
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "tenant_rate_limited_requests_total",
+			Help:      "Number of requests rejected by the per-tenant rate limiter, for tenants which made requests recently.",
+		}, []string{"tenant", "protocol"}),
+		overrides: map[string]RateLimit{},
+		buckets:   map[string]*tokenBucket{},
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if reg != nil {
+		if err := reg.Register(l.throttled); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.ReloadOverrides(); err != nil {
+		return nil, err
+	}
+
+	if cfg.OverridesFile != "" && cfg.ReloadPeriod > 0 {
+		go l.loop()
+	} else {
+		close(l.done)
+	}
+	return l, nil
+}
+
+func (l *TenantRateLimiter) loop() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.ReloadPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.ReloadOverrides(); err != nil {
+				l.log.Warnf("error reloading rate limit overrides: %v", err)
+			}
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// Stop reloading the overrides file.
+func (l *TenantRateLimiter) Stop() {
+	close(l.quit)
+	<-l.done
+}
+
+// ReloadOverrides re-reads the overrides file. On error the previous overrides are kept.
+func (l *TenantRateLimiter) ReloadOverrides() error {
+	if l.cfg.OverridesFile == "" {
+		return nil
+	}
+	buf, err := os.ReadFile(l.cfg.OverridesFile)
+	if err != nil {
+		return err
+	}
+	var overrides RateLimitOverrides
+	if err := yaml.UnmarshalStrict(buf, &overrides); err != nil {
+		return fmt.Errorf("error parsing %s: %v", l.cfg.OverridesFile, err)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.overrides = overrides.Overrides
+	return nil
+}
+
+func (l *TenantRateLimiter) limit(tenant string) RateLimit {
+	if limit, ok := l.overrides[tenant]; ok {
+		return limit
+	}
+	return l.cfg.Default
+}
+
+// Allow takes a token for tenant. If none is available, it returns false and how
+// long until one will be.
+func (l *TenantRateLimiter) Allow(tenant string) (bool, time.Duration) {
+	now := mtime.Now()
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.evict(now)
+
+	limit := l.limit(tenant)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+	b, ok := l.buckets[tenant]
+	if !ok {
+		b = &tokenBucket{tokens: limit.burst(), last: now}
+		l.buckets[tenant] = b
+	}
+	return b.take(limit, now)
+}
+
+// evict forgets the buckets of tenants which have been idle long enough for
+// them to refill, as a new bucket would be the same, along with their metrics.
+func (l *TenantRateLimiter) evict(now time.Time) {
+	if now.Sub(l.lastEviction) < evictInterval {
+		return
+	}
+	l.lastEviction = now
+	for tenant, b := range l.buckets {
+		if now.Sub(b.last) >= bucketIdleTimeout && b.full(l.limit(tenant), now) {
+			delete(l.buckets, tenant)
+			l.throttled.DeleteLabelValues(tenant, "http")
+			l.throttled.DeleteLabelValues(tenant, "grpc")
+		}
+	}
+}
+
+// Wrap implements Interface.
+func (l *TenantRateLimiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := user.ExtractOrgID(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if ok, retryAfter := l.Allow(tenant); !ok {
+			l.throttled.WithLabelValues(tenant, "http").Inc()
+			w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+			http.Error(w, fmt.Sprintf("rate limit exceeded for tenant %s", tenant), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *TenantRateLimiter) allowGRPC(ctx context.Context) error {
+	tenant, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	ok, retryAfter := l.Allow(tenant)
+	if ok {
+		return nil
+	}
+	l.throttled.WithLabelValues(tenant, "grpc").Inc()
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfterSeconds(retryAfter)))
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for tenant %s", tenant)
+}
+
+// UnaryServerInterceptor rejects gRPC requests over the tenant's rate limit.
+func (l *TenantRateLimiter) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.allowGRPC(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor rejects gRPC streams over the tenant's rate limit.
+func (l *TenantRateLimiter) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allowGRPC(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// Retry-After is in whole seconds; round up so clients don't retry too early.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(limit.burst(), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// full returns true if the bucket will have refilled by now.
+func (b *tokenBucket) full(limit RateLimit, now time.Time) bool {
+	return limit.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= limit.burst()
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks/common/logging"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/user"
+)
+
+func TestTenantRateLimiterHTTP(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	l, err := NewTenantRateLimiter(RateLimiterConfig{Default: RateLimit{Rate: 1, Burst: 2}}, logging.Noop(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	defer l.Stop()
+
+	handler := l.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tenant != "" {
+			req = req.WithContext(user.InjectOrgID(req.Context(), tenant))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("a").Code)
+	require.Equal(t, http.StatusOK, do("a").Code)
+	rec := do("a")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Other tenants are unaffected, and requests without a tenant rejected.
+	require.Equal(t, http.StatusOK, do("b").Code)
+	require.Equal(t, http.StatusUnauthorized, do("").Code)
+
+	mtime.NowForce(now.Add(time.Second))
+	require.Equal(t, http.StatusOK, do("a").Code)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(l.throttled.WithLabelValues("a", "http")))
+}
+
+func TestTenantRateLimiterOverrides(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("overrides:\n  noisy:\n    rate: 1\n    burst: 1\n"), 0o600))
+
+	l, err := NewTenantRateLimiter(RateLimiterConfig{OverridesFile: path}, logging.Noop(), nil)
+	require.NoError(t, err)
+	defer l.Stop()
+
+	ok, _ := l.Allow("noisy")
+	require.True(t, ok)
+	ok, retryAfter := l.Allow("noisy")
+	require.False(t, ok)
+	require.Equal(t, time.Second, retryAfter)
+
+	// Tenants without overrides use the unlimited default.
+	for i := 0; i < 10; i++ {
+		ok, _ = l.Allow("quiet")
+		require.True(t, ok)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("overrides: {}\n"), 0o600))
+	require.NoError(t, l.ReloadOverrides())
+	ok, _ = l.Allow("noisy")
+	require.True(t, ok)
+
+	// A broken file keeps the previous overrides.
+	require.NoError(t, os.WriteFile(path, []byte("overrides: [\n"), 0o600))
+	require.Error(t, l.ReloadOverrides())
+}
+
+func TestTenantRateLimiterGRPC(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	l, err := NewTenantRateLimiter(RateLimiterConfig{Default: RateLimit{Rate: 1, Burst: 1}}, logging.Noop(), nil)
+	require.NoError(t, err)
+	defer l.Stop()
+
+	ctx := user.InjectOrgID(context.Background(), "a")
+	info := &grpc.UnaryServerInfo{FullMethod: "Test"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	_, err = l.UnaryServerInterceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	_, err = l.UnaryServerInterceptor(ctx, nil, info, handler)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = l.UnaryServerInterceptor(context.Background(), nil, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTenantRateLimiterEviction(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	l, err := NewTenantRateLimiter(RateLimiterConfig{MetricsNamespace: "test", Default: RateLimit{Rate: 0.001, Burst: 1}}, logging.Noop(), nil)
+	require.NoError(t, err)
+	defer l.Stop()
+
+	ok, _ := l.Allow("a")
+	require.True(t, ok)
+	ok, _ = l.Allow("a")
+	require.False(t, ok)
+	l.throttled.WithLabelValues("a", "http").Inc()
+
+	// The bucket is idle, but hasn't refilled.
+	mtime.NowForce(now.Add(bucketIdleTimeout))
+	ok, _ = l.Allow("b")
+	require.True(t, ok)
+	require.Len(t, l.buckets, 2)
+	require.Equal(t, 1, testutil.CollectAndCount(l.throttled))
+
+	mtime.NowForce(now.Add(1000 * time.Second))
+	ok, _ = l.Allow("c")
+	require.True(t, ok)
+	require.Len(t, l.buckets, 2)
+	require.NotContains(t, l.buckets, "a")
+	require.Equal(t, 0, testutil.CollectAndCount(l.throttled))
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// HealthCheck reports whether a component is healthy. A nil error means healthy.
+type HealthCheck func(ctx context.Context) error
+
+// How often Watch calls on the gRPC health service re-evaluate the checks.
+const healthWatchInterval = time.Second
+
+const (
+	healthStatusOK     = "ok"
+	healthStatusFailed = "failed"
+)
+
+// Health tracks named readiness and liveness checks registered by components.
+// It serves them over HTTP as /ready and /healthz, and over gRPC as the
+// standard grpc.health.v1 service.
+//
+// Readiness fails as soon as the server starts shutting down; liveness does not.
+type Health struct {
+	mtx          sync.RWMutex
+	readiness    map[string]HealthCheck
+	liveness     map[string]HealthCheck
+	shuttingDown bool
+}
+
+// NewHealth makes a new Health with no checks.
+func NewHealth() *Health {
+	return &Health{
+		readiness: map[string]HealthCheck{},
+		liveness:  map[string]HealthCheck{},
+	}
+}
+
+// AddReadinessCheck registers a check that must pass for the server to be ready.
+// Registering a check under an existing name replaces it.
+func (h *Health) AddReadinessCheck(name string, check HealthCheck) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.readiness[name] = check
+}
+
+// AddLivenessCheck registers a check that must pass for the server to be live.
+// Registering a check under an existing name replaces it.
+func (h *Health) AddLivenessCheck(name string, check HealthCheck) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.liveness[name] = check
+}
+
+// SetShuttingDown marks the server as no longer ready.
+func (h *Health) SetShuttingDown() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.shuttingDown = true
+}
+
+// ShuttingDown returns true once SetShuttingDown has been called.
+func (h *Health) ShuttingDown() bool {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.shuttingDown
+}
+
+// HealthCheckResult is the outcome of a single check.
+type HealthCheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthReport is the outcome of all readiness or liveness checks.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// Healthy returns true if every check passed.
+func (r HealthReport) Healthy() bool {
+	return r.Status == healthStatusOK
+}
+
+// Ready runs all readiness checks.
+func (h *Health) Ready(ctx context.Context) HealthReport {
+	h.mtx.RLock()
+	checks := copyChecks(h.readiness)
+	shuttingDown := h.shuttingDown
+	h.mtx.RUnlock()
+
+	report := runChecks(ctx, checks)
+	if shuttingDown {
+		report.Status = healthStatusFailed
+		report.Checks["shutdown"] = HealthCheckResult{Status: healthStatusFailed, Error: "server is shutting down"}
+	}
+	return report
+}
+
+// Live runs all liveness checks.
+func (h *Health) Live(ctx context.Context) HealthReport {
+	h.mtx.RLock()
+	checks := copyChecks(h.liveness)
+	h.mtx.RUnlock()
+
+	return runChecks(ctx, checks)
+}
+
+func copyChecks(checks map[string]HealthCheck) map[string]HealthCheck {
+	result := make(map[string]HealthCheck, len(checks))
+	for name, check := range checks {
+		result[name] = check
+	}
+	return result
+}
+
+func runChecks(ctx context.Context, checks map[string]HealthCheck) HealthReport {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := HealthReport{Status: healthStatusOK, Checks: map[string]HealthCheckResult{}}
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			report.Status = healthStatusFailed
+			report.Checks[name] = HealthCheckResult{Status: healthStatusFailed, Error: err.Error()}
+		} else {
+			report.Checks[name] = HealthCheckResult{Status: healthStatusOK}
+		}
+	}
+	return report
+}
+
+// ReadyHandler serves the readiness report as JSON, with a 503 if not ready.
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Ready(r.Context()))
+	})
+}
+
+// LiveHandler serves the liveness report as JSON, with a 503 if not live.
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Live(r.Context()))
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// RegisterHealth registers /ready and /healthz on the given router.
+func RegisterHealth(router *mux.Router, h *Health) {
+	router.Handle("/ready", h.ReadyHandler())
+	router.Handle("/healthz", h.LiveHandler())
+}
+
+// servingStatus returns the gRPC status of the named readiness check, or of
+// all of them if service is empty.
+func (h *Health) servingStatus(ctx context.Context, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, bool) {
+	if service == "" {
+		if h.Ready(ctx).Healthy() {
+			return grpc_health_v1.HealthCheckResponse_SERVING, true
+		}
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, true
+	}
+
+	h.mtx.RLock()
+	check, ok := h.readiness[service]
+	shuttingDown := h.shuttingDown
+	h.mtx.RUnlock()
+	if !ok {
+		return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if shuttingDown || check(ctx) != nil {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, true
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING, true
+}
+
+// Check implements grpc_health_v1.HealthServer.
+func (h *Health) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	s, ok := h.servingStatus(ctx, req.Service)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: s}, nil
+}
+
+// Watch implements grpc_health_v1.HealthServer.
+func (h *Health) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	var last grpc_health_v1.HealthCheckResponse_ServingStatus = -1
+	for {
+		s, _ := h.servingStatus(ctx, req.Service)
+		if s != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: s}); err != nil {
+				return err
+			}
+			last = s
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	h := NewHealth()
+	var dbErr error
+	h.AddReadinessCheck("db", func(context.Context) error { return dbErr })
+	h.AddLivenessCheck("loop", func(context.Context) error { return nil })
+
+	get := func(handler http.Handler) (int, HealthReport) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		var report HealthReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, report := get(h.ReadyHandler())
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, HealthReport{Status: "ok", Checks: map[string]HealthCheckResult{"db": {Status: "ok"}}}, report)
+
+	dbErr = errors.New("connection refused")
+	code, report = get(h.ReadyHandler())
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, HealthReport{Status: "failed", Checks: map[string]HealthCheckResult{"db": {Status: "failed", Error: "connection refused"}}}, report)
+
+	dbErr = nil
+	h.SetShuttingDown()
+	code, report = get(h.ReadyHandler())
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "failed", report.Checks["shutdown"].Status)
+
+	// Liveness is unaffected by shutting down.
+	code, report = get(h.LiveHandler())
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, HealthReport{Status: "ok", Checks: map[string]HealthCheckResult{"loop": {Status: "ok"}}}, report)
+}
+
+func TestHealthGRPC(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.HTTPListenPort = 9196
+	cfg.GRPCListenAddress = "localhost"
+	cfg.GRPCListenPort = 9197
+	cfg.MetricsNamespace = "testing_health"
+	cfg.RegisterHealthEndpoints = true
+	server, err := New(cfg)
+	require.NoError(t, err)
+
+	server.Health.AddReadinessCheck("db", func(context.Context) error { return nil })
+
+	go server.Run()
+
+	conn, err := grpc.Dial("localhost:9197", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	resp, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "db"})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := http.Get("http://127.0.0.1:9196/ready")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	server.Health.SetShuttingDown()
+	resp, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	server.Shutdown()
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// NetworkUnix listens on a unix domain socket; the listen address is the socket path.
+	NetworkUnix = "unix"
+	// NetworkSystemd uses a socket passed in by systemd socket activation.
+	// The listen address selects the socket by its FileDescriptorName; if
+	// blank, the HTTP server takes the first socket and gRPC the second.
+	NetworkSystemd = "systemd"
+)
+
+// First file descriptor passed by systemd, see sd_listen_fds(3).
+const listenFDsStart = 3
+
+// listen creates the listener described by network, address and port.
+// index is the position of the listener to use for socket activation when no
+// address is given.
+func listen(network, address string, port int, socketMode os.FileMode, index int) (net.Listener, error) {
+	switch network {
+	case "":
+		network = DefaultNetwork
+	case NetworkUnix:
+		return listenUnix(address, socketMode)
+	case NetworkSystemd:
+		return listenSystemd(address, index)
+	}
+	return net.Listen(network, fmt.Sprintf("%s:%d", address, port))
+}
+
+// listenUnix listens on the unix socket at path, removing any stale socket left
+// behind by a previous process which didn't shut down cleanly.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path must be set as the listen address")
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen(NetworkUnix, path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("error setting permissions on unix socket %s: %v", path, err)
+		}
+	}
+	return l, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	// If something answers, the socket is in use and not stale.
+	conn, err := net.DialTimeout(NetworkUnix, path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+type activatedFD struct {
+	fd   int
+	name string
+}
+
+// activatedFDs returns the file descriptors passed to this process by socket
+// activation, following the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES protocol.
+func activatedFDs() ([]activatedFD, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by socket activation (LISTEN_PID is not set to this process)")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, errors.New("no sockets passed by socket activation (LISTEN_FDS is not set)")
+	}
+	var names []string
+	if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+		names = strings.Split(s, ":")
+	}
+
+	fds := make([]activatedFD, 0, count)
+	for i := 0; i < count; i++ {
+		fd := activatedFD{fd: listenFDsStart + i}
+		if i < len(names) {
+			fd.name = names[i]
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+func selectActivatedFD(fds []activatedFD, name string, index int) (activatedFD, error) {
+	if name == "" {
+		if index >= len(fds) {
+			return activatedFD{}, fmt.Errorf("socket activation passed %d sockets, need at least %d", len(fds), index+1)
+		}
+		return fds[index], nil
+	}
+	for _, fd := range fds {
+		if fd.name == name {
+			return fd, nil
+		}
+	}
+	return activatedFD{}, fmt.Errorf("no socket named %q passed by socket activation", name)
+}
+
+func listenSystemd(name string, index int) (net.Listener, error) {
+	fds, err := activatedFDs()
+	if err != nil {
+		return nil, err
+	}
+	fd, err := selectActivatedFD(fds, name, index)
+	if err != nil {
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd.fd), fd.name)
+	defer f.Close() // FileListener has its own copy of the descriptor.
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error using socket activation file descriptor %d: %v", fd.fd, err)
+	}
+	return l, nil
+}
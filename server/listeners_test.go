@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.sock")
+
+	// Leave a stale socket behind, as a crashed process would.
+	stale, err := net.Listen(NetworkUnix, path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	l, err := listenUnix(path, 0600)
+	require.NoError(t, err)
+	defer l.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The socket is now live, so must not be removed.
+	_, err = listenUnix(path, 0600)
+	require.EqualError(t, err, fmt.Sprintf("unix socket %s is already in use", path))
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		HTTPListenNetwork: NetworkUnix,
+		HTTPListenAddress: filepath.Join(dir, "http.sock"),
+		GRPCListenNetwork: NetworkUnix,
+		GRPCListenAddress: filepath.Join(dir, "grpc.sock"),
+		HTTPConnLimit:     1,
+		Registerer:        prometheus.NewRegistry(),
+	}
+	server, err := New(cfg)
+	require.NoError(t, err)
+
+	server.HTTP.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+	go server.Run()
+	defer server.Shutdown()
+
+	client := http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return net.Dial(NetworkUnix, cfg.HTTPListenAddress)
+		},
+	}}
+	res, err := client.Get("http://unix/test")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "OK", string(body))
+	require.Equal(t, NetworkUnix, server.GRPCListenAddr().Network())
+}
+
+func TestActivatedFDs(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "2")
+	_, err := activatedFDs()
+	require.Error(t, err)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDNAMES", "http:grpc")
+	fds, err := activatedFDs()
+	require.NoError(t, err)
+	require.Equal(t, []activatedFD{{fd: 3, name: "http"}, {fd: 4, name: "grpc"}}, fds)
+
+	fd, err := selectActivatedFD(fds, "grpc", 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, fd.fd)
+
+	fd, err = selectActivatedFD(fds, "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, fd.fd)
+
+	_, err = selectActivatedFD(fds, "", 2)
+	require.Error(t, err)
+	_, err = selectActivatedFD(fds, "metrics", 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks/common/logging"
+)
+
+// LogLevelStatus is the current log level, as served by /log_level.
+type LogLevelStatus struct {
+	Level     string             `json:"level"`
+	RevertAt  *time.Time         `json:"revert_at,omitempty"`
+	Overrides []LogLevelOverride `json:"overrides,omitempty"`
+}
+
+func logLevelStatus(level *logging.DynamicLevel) LogLevelStatus {
+	l := level.Level()
+	s := LogLevelStatus{Level: l.String(), RevertAt: revertTime(level.RevertAt())}
+	for _, o := range level.Overrides() {
+		override := LogLevelOverride{Level: o.Level.String(), RevertAt: revertTime(o.RevertAt)}
+		if o.Field == logging.OrgIDField {
+			override.OrgID = o.Value
+		} else {
+			override.Component = o.Value
+		}
+		s.Overrides = append(s.Overrides, override)
+	}
+	return s
+}
+
+func revertTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// logLevelChange is a request to change the log level, or, if component or
+// orgID is set, its override for them.
+type logLevelChange struct {
+	level     string
+	ttl       time.Duration
+	component string
+	orgID     string
+}
+
+// setLogLevel makes the change, for its ttl if positive, or defaultTTL if
+// it is zero. An override is removed if the level is empty.
+func setLogLevel(level *logging.DynamicLevel, c logLevelChange, defaultTTL time.Duration, log logging.Interface) error {
+	var field, value string
+	switch {
+	case c.component != "" && c.orgID != "":
+		return fmt.Errorf("can't override the level for both a component and an org")
+	case c.component != "":
+		field, value = logging.ComponentField, c.component
+	case c.orgID != "":
+		field, value = logging.OrgIDField, c.orgID
+	}
+	if field != "" && c.level == "" {
+		level.ClearOverride(field, value)
+		log.Warnf("log level override for %s=%s removed", field, value)
+		return nil
+	}
+
+	var l logging.Level
+	if err := l.Set(c.level); err != nil {
+		return err
+	}
+	if c.ttl < 0 {
+		return fmt.Errorf("negative ttl %s", c.ttl)
+	}
+	ttl := c.ttl
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	target := ""
+	if field != "" {
+		if err := level.SetOverride(field, value, l, ttl); err != nil {
+			return err
+		}
+		target = fmt.Sprintf(" for %s=%s", field, value)
+	} else {
+		level.Set(l, ttl)
+	}
+	if ttl > 0 {
+		log.Warnf("log level%s set to %s for %s", target, l.String(), ttl)
+	} else {
+		log.Warnf("log level%s set to %s", target, l.String())
+	}
+	return nil
+}
+
+// LogLevelHandler serves the log level as JSON on GET, and changes it on PUT
+// to the "level" form value, for the "ttl" form value if it is set, or
+// defaultTTL. A ttl of 0 keeps the level until it is changed again.
+//
+// If the "component" or "org_id" form value is set, PUT changes the override
+// for that component or org instead, and DELETE removes it. DELETE takes them
+// from the query.
+func LogLevelHandler(level *logging.DynamicLevel, defaultTTL time.Duration, log logging.Interface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodDelete:
+			c := logLevelChange{
+				component: r.FormValue("component"),
+				orgID:     r.FormValue("org_id"),
+			}
+			if r.Method == http.MethodPut {
+				c.level = r.FormValue("level")
+				if s := r.FormValue("ttl"); s != "" {
+					var err error
+					if c.ttl, err = time.ParseDuration(s); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+				}
+			} else if c.component == "" && c.orgID == "" {
+				http.Error(w, "only overrides can be deleted", http.StatusBadRequest)
+				return
+			}
+			if err := setLogLevel(level, c, defaultTTL, log); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(logLevelStatus(level))
+	})
+}
+
+// RegisterLogLevel registers /log_level on the given router.
+func RegisterLogLevel(router *mux.Router, level *logging.DynamicLevel, defaultTTL time.Duration, log logging.Interface) {
+	router.Handle("/log_level", LogLevelHandler(level, defaultTTL, log))
+}
+
+// NewLogLevelServer makes a LogLevelServer which changes level, for
+// defaultTTL unless the request sets a TTL.
+func NewLogLevelServer(level *logging.DynamicLevel, defaultTTL time.Duration, log logging.Interface) LogLevelServer {
+	return &logLevelServer{level: level, defaultTTL: defaultTTL, log: log}
+}
+
+type logLevelServer struct {
+	level      *logging.DynamicLevel
+	defaultTTL time.Duration
+	log        logging.Interface
+}
+
+func (s *logLevelServer) response() *LogLevelResponse {
+	status := logLevelStatus(s.level)
+	return &LogLevelResponse{Level: status.Level, RevertAt: status.RevertAt, Overrides: status.Overrides}
+}
+
+// GetLogLevel implements LogLevelServer.
+func (s *logLevelServer) GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevelResponse, error) {
+	return s.response(), nil
+}
+
+// SetLogLevel implements LogLevelServer.
+func (s *logLevelServer) SetLogLevel(_ context.Context, req *SetLogLevelRequest) (*LogLevelResponse, error) {
+	c := logLevelChange{level: req.Level, component: req.Component, orgID: req.OrgID}
+	if req.TTL != nil {
+		c.ttl = *req.TTL
+	}
+	if err := setLogLevel(s.level, c, s.defaultTTL, s.log); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.response(), nil
+}
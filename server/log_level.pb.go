@@ -0,0 +1,1622 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: server/log_level.proto
+
+package server
+
+import (
+	context "context"
+	fmt "fmt"
+	_ "github.com/gogo/protobuf/gogoproto"
+	proto "github.com/gogo/protobuf/proto"
+	_ "github.com/gogo/protobuf/types"
+	github_com_gogo_protobuf_types "github.com/gogo/protobuf/types"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+	reflect "reflect"
+	strings "strings"
+	time "time"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+var _ = time.Kitchen
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type GetLogLevelRequest struct {
+}
+
+func (m *GetLogLevelRequest) Reset()      { *m = GetLogLevelRequest{} }
+func (*GetLogLevelRequest) ProtoMessage() {}
+func (*GetLogLevelRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2a8c66416ac54c7d, []int{0}
+}
+func (m *GetLogLevelRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetLogLevelRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetLogLevelRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *GetLogLevelRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLogLevelRequest.Merge(m, src)
+}
+func (m *GetLogLevelRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetLogLevelRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLogLevelRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLogLevelRequest proto.InternalMessageInfo
+
+type SetLogLevelRequest struct {
+	// One of debug, info, warn or error. May be empty when setting an override,
+	// to remove it.
+	Level string `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	// How long until the level reverts, if set. Otherwise the server's default
+	// applies.
+	TTL *time.Duration `protobuf:"bytes,2,opt,name=ttl,proto3,stdduration" json:"ttl,omitempty"`
+	// If one of these is set, the level only applies to log entries from that
+	// component, or for that org.
+	Component string `protobuf:"bytes,3,opt,name=component,proto3" json:"component,omitempty"`
+	OrgID     string `protobuf:"bytes,4,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+}
+
+func (m *SetLogLevelRequest) Reset()      { *m = SetLogLevelRequest{} }
+func (*SetLogLevelRequest) ProtoMessage() {}
+func (*SetLogLevelRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2a8c66416ac54c7d, []int{1}
+}
+func (m *SetLogLevelRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SetLogLevelRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SetLogLevelRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SetLogLevelRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetLogLevelRequest.Merge(m, src)
+}
+func (m *SetLogLevelRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SetLogLevelRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetLogLevelRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetLogLevelRequest proto.InternalMessageInfo
+
+func (m *SetLogLevelRequest) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *SetLogLevelRequest) GetTTL() *time.Duration {
+	if m != nil {
+		return m.TTL
+	}
+	return nil
+}
+
+func (m *SetLogLevelRequest) GetComponent() string {
+	if m != nil {
+		return m.Component
+	}
+	return ""
+}
+
+func (m *SetLogLevelRequest) GetOrgID() string {
+	if m != nil {
+		return m.OrgID
+	}
+	return ""
+}
+
+type LogLevelResponse struct {
+	Level string `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	// When the level will revert, if it will.
+	RevertAt  *time.Time         `protobuf:"bytes,2,opt,name=revert_at,json=revertAt,proto3,stdtime" json:"revert_at,omitempty"`
+	Overrides []LogLevelOverride `protobuf:"bytes,3,rep,name=overrides,proto3" json:"overrides"`
+}
+
+func (m *LogLevelResponse) Reset()      { *m = LogLevelResponse{} }
+func (*LogLevelResponse) ProtoMessage() {}
+func (*LogLevelResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2a8c66416ac54c7d, []int{2}
+}
+func (m *LogLevelResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LogLevelResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LogLevelResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LogLevelResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogLevelResponse.Merge(m, src)
+}
+func (m *LogLevelResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *LogLevelResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogLevelResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogLevelResponse proto.InternalMessageInfo
+
+func (m *LogLevelResponse) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *LogLevelResponse) GetRevertAt() *time.Time {
+	if m != nil {
+		return m.RevertAt
+	}
+	return nil
+}
+
+func (m *LogLevelResponse) GetOverrides() []LogLevelOverride {
+	if m != nil {
+		return m.Overrides
+	}
+	return nil
+}
+
+type LogLevelOverride struct {
+	// One of these is set.
+	Component string `protobuf:"bytes,1,opt,name=component,proto3" json:"component,omitempty"`
+	OrgID     string `protobuf:"bytes,2,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	Level     string `protobuf:"bytes,3,opt,name=level,proto3" json:"level,omitempty"`
+	// When the override will be removed, if it will.
+	RevertAt *time.Time `protobuf:"bytes,4,opt,name=revert_at,json=revertAt,proto3,stdtime" json:"revert_at,omitempty"`
+}
+
+func (m *LogLevelOverride) Reset()      { *m = LogLevelOverride{} }
+func (*LogLevelOverride) ProtoMessage() {}
+func (*LogLevelOverride) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2a8c66416ac54c7d, []int{3}
+}
+func (m *LogLevelOverride) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LogLevelOverride) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LogLevelOverride.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LogLevelOverride) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogLevelOverride.Merge(m, src)
+}
+func (m *LogLevelOverride) XXX_Size() int {
+	return m.Size()
+}
+func (m *LogLevelOverride) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogLevelOverride.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogLevelOverride proto.InternalMessageInfo
+
+func (m *LogLevelOverride) GetComponent() string {
+	if m != nil {
+		return m.Component
+	}
+	return ""
+}
+
+func (m *LogLevelOverride) GetOrgID() string {
+	if m != nil {
+		return m.OrgID
+	}
+	return ""
+}
+
+func (m *LogLevelOverride) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *LogLevelOverride) GetRevertAt() *time.Time {
+	if m != nil {
+		return m.RevertAt
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*GetLogLevelRequest)(nil), "server.GetLogLevelRequest")
+	proto.RegisterType((*SetLogLevelRequest)(nil), "server.SetLogLevelRequest")
+	proto.RegisterType((*LogLevelResponse)(nil), "server.LogLevelResponse")
+	proto.RegisterType((*LogLevelOverride)(nil), "server.LogLevelOverride")
+}
+
+func init() { proto.RegisterFile("server/log_level.proto", fileDescriptor_2a8c66416ac54c7d) }
+
+var fileDescriptor_2a8c66416ac54c7d = []byte{
+	// 452 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x3d, 0x6f, 0xd3, 0x40,
+	0x18, 0xc7, 0x7d, 0x75, 0x12, 0xd5, 0x97, 0x05, 0x9d, 0x22, 0x64, 0x2c, 0x74, 0x8e, 0xcc, 0x92,
+	0xc9, 0x96, 0xc2, 0xc2, 0x00, 0x03, 0x56, 0x11, 0xaa, 0x14, 0x54, 0xc9, 0xc9, 0xc4, 0x12, 0xe5,
+	0xe5, 0xe1, 0xb0, 0xb0, 0xfd, 0x84, 0xf3, 0xc5, 0x5d, 0xf9, 0x08, 0x1d, 0xfb, 0x01, 0x40, 0xf0,
+	0x51, 0x3a, 0x76, 0xec, 0x14, 0xa8, 0xf3, 0x05, 0xf8, 0x08, 0xc8, 0x76, 0xac, 0x94, 0xba, 0x19,
+	0xba, 0xdd, 0x3d, 0x6f, 0xff, 0xdf, 0xff, 0xb9, 0xa3, 0x4f, 0x53, 0x90, 0x19, 0x48, 0x2f, 0x42,
+	0x31, 0x8d, 0x20, 0x83, 0xc8, 0x5d, 0x49, 0x54, 0xc8, 0x3a, 0x55, 0xdc, 0xea, 0x09, 0x14, 0x58,
+	0x86, 0xbc, 0xe2, 0x54, 0x65, 0x2d, 0x2e, 0x10, 0x45, 0x04, 0x5e, 0x79, 0x9b, 0xaf, 0x3f, 0x79,
+	0xcb, 0xb5, 0x9c, 0xa9, 0x10, 0x93, 0x5d, 0xde, 0xbe, 0x9f, 0x57, 0x61, 0x0c, 0xa9, 0x9a, 0xc5,
+	0xab, 0xaa, 0xc0, 0xe9, 0x51, 0xf6, 0x1e, 0xd4, 0x08, 0xc5, 0xa8, 0xd0, 0x0c, 0xe0, 0xeb, 0x1a,
+	0x52, 0xe5, 0x7c, 0x27, 0x94, 0x8d, 0x1b, 0x61, 0xd6, 0xa3, 0xed, 0x12, 0xcd, 0x24, 0x7d, 0x32,
+	0x30, 0x82, 0xea, 0xc2, 0x5e, 0x51, 0x5d, 0xa9, 0xc8, 0x3c, 0xea, 0x93, 0x41, 0x77, 0xf8, 0xcc,
+	0xad, 0x14, 0xdd, 0x5a, 0xd1, 0x3d, 0xd9, 0x11, 0xf9, 0xdd, 0x7c, 0x63, 0xeb, 0x93, 0xc9, 0xe8,
+	0xf2, 0xb7, 0x4d, 0x82, 0xa2, 0x85, 0x3d, 0xa7, 0xc6, 0x02, 0xe3, 0x15, 0x26, 0x90, 0x28, 0x53,
+	0x2f, 0x67, 0xee, 0x03, 0xac, 0x4f, 0x3b, 0x28, 0xc5, 0x34, 0x5c, 0x9a, 0xad, 0x22, 0xe5, 0x1b,
+	0xf9, 0xc6, 0x6e, 0x9f, 0x49, 0x71, 0x7a, 0x12, 0xb4, 0x51, 0x8a, 0xd3, 0xa5, 0xf3, 0x83, 0xd0,
+	0x27, 0x7b, 0xc6, 0x74, 0x85, 0x49, 0x0a, 0x07, 0x20, 0xdf, 0x50, 0x43, 0x42, 0x06, 0x52, 0x4d,
+	0x67, 0x6a, 0x87, 0x6a, 0x35, 0x50, 0x27, 0xf5, 0x72, 0xfc, 0xd6, 0x45, 0x01, 0x79, 0x5c, 0xb5,
+	0xbc, 0x55, 0xec, 0x35, 0x35, 0x30, 0x03, 0x29, 0xc3, 0x25, 0xa4, 0xa6, 0xde, 0xd7, 0x07, 0xdd,
+	0xa1, 0xe9, 0x56, 0x2f, 0xe3, 0xd6, 0x04, 0x67, 0xbb, 0x02, 0xbf, 0x75, 0xb5, 0xb1, 0xb5, 0x60,
+	0xdf, 0xe0, 0xfc, 0xbc, 0xc3, 0x59, 0x57, 0xfd, 0x6f, 0x9e, 0x1c, 0x36, 0x7f, 0xf4, 0xb0, 0xf9,
+	0xbd, 0x4f, 0xfd, 0xa0, 0xcf, 0xd6, 0x63, 0x7d, 0x0e, 0x2f, 0x09, 0x3d, 0xae, 0x49, 0xd9, 0x3b,
+	0xda, 0xbd, 0xf3, 0x37, 0x98, 0x55, 0x1b, 0x6e, 0x7e, 0x18, 0xab, 0xb1, 0x8c, 0xfa, 0x39, 0x1c,
+	0xad, 0x18, 0x33, 0x7e, 0x68, 0xcc, 0xf8, 0x51, 0x63, 0xfc, 0x0f, 0x37, 0xb7, 0x5c, 0xfb, 0x7b,
+	0xcb, 0xc9, 0xb7, 0x9c, 0x93, 0x5f, 0x39, 0x27, 0x57, 0x39, 0x27, 0xd7, 0x39, 0x27, 0x7f, 0x72,
+	0x4e, 0x2e, 0xb6, 0x5c, 0xbb, 0xde, 0x72, 0xed, 0x66, 0xcb, 0xb5, 0x8f, 0x2f, 0x44, 0xa8, 0x3e,
+	0xaf, 0xe7, 0xee, 0x02, 0x63, 0xef, 0x1c, 0x66, 0x19, 0x9c, 0xa3, 0xfc, 0x92, 0x7a, 0x0b, 0x8c,
+	0x63, 0x4c, 0xbc, 0x4a, 0x61, 0xde, 0x29, 0xb7, 0xf1, 0xf2, 0xdf, 0x00, 0x7a, 0x25, 0xeb, 0x3a,
+	0x78, 0x03, 0x00, 0x00,
+}
+
+func (this *GetLogLevelRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*GetLogLevelRequest)
+	if !ok {
+		that2, ok := that.(GetLogLevelRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *SetLogLevelRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SetLogLevelRequest)
+	if !ok {
+		that2, ok := that.(SetLogLevelRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Level != that1.Level {
+		return false
+	}
+	if this.TTL != nil && that1.TTL != nil {
+		if *this.TTL != *that1.TTL {
+			return false
+		}
+	} else if this.TTL != nil {
+		return false
+	} else if that1.TTL != nil {
+		return false
+	}
+	if this.Component != that1.Component {
+		return false
+	}
+	if this.OrgID != that1.OrgID {
+		return false
+	}
+	return true
+}
+func (this *LogLevelResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LogLevelResponse)
+	if !ok {
+		that2, ok := that.(LogLevelResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Level != that1.Level {
+		return false
+	}
+	if that1.RevertAt == nil {
+		if this.RevertAt != nil {
+			return false
+		}
+	} else if !this.RevertAt.Equal(*that1.RevertAt) {
+		return false
+	}
+	if len(this.Overrides) != len(that1.Overrides) {
+		return false
+	}
+	for i := range this.Overrides {
+		if !this.Overrides[i].Equal(&that1.Overrides[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *LogLevelOverride) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LogLevelOverride)
+	if !ok {
+		that2, ok := that.(LogLevelOverride)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Component != that1.Component {
+		return false
+	}
+	if this.OrgID != that1.OrgID {
+		return false
+	}
+	if this.Level != that1.Level {
+		return false
+	}
+	if that1.RevertAt == nil {
+		if this.RevertAt != nil {
+			return false
+		}
+	} else if !this.RevertAt.Equal(*that1.RevertAt) {
+		return false
+	}
+	return true
+}
+func (this *GetLogLevelRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&server.GetLogLevelRequest{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SetLogLevelRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&server.SetLogLevelRequest{")
+	s = append(s, "Level: "+fmt.Sprintf("%#v", this.Level)+",\n")
+	s = append(s, "TTL: "+fmt.Sprintf("%#v", this.TTL)+",\n")
+	s = append(s, "Component: "+fmt.Sprintf("%#v", this.Component)+",\n")
+	s = append(s, "OrgID: "+fmt.Sprintf("%#v", this.OrgID)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LogLevelResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&server.LogLevelResponse{")
+	s = append(s, "Level: "+fmt.Sprintf("%#v", this.Level)+",\n")
+	s = append(s, "RevertAt: "+fmt.Sprintf("%#v", this.RevertAt)+",\n")
+	if this.Overrides != nil {
+		vs := make([]LogLevelOverride, len(this.Overrides))
+		for i := range vs {
+			vs[i] = this.Overrides[i]
+		}
+		s = append(s, "Overrides: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LogLevelOverride) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&server.LogLevelOverride{")
+	s = append(s, "Component: "+fmt.Sprintf("%#v", this.Component)+",\n")
+	s = append(s, "OrgID: "+fmt.Sprintf("%#v", this.OrgID)+",\n")
+	s = append(s, "Level: "+fmt.Sprintf("%#v", this.Level)+",\n")
+	s = append(s, "RevertAt: "+fmt.Sprintf("%#v", this.RevertAt)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringLogLevel(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// LogLevelClient is the client API for LogLevel service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type LogLevelClient interface {
+	GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error)
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error)
+}
+
+type logLevelClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewLogLevelClient(cc *grpc.ClientConn) LogLevelClient {
+	return &logLevelClient{cc}
+}
+
+func (c *logLevelClient) GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error) {
+	out := new(LogLevelResponse)
+	err := c.cc.Invoke(ctx, "/server.LogLevel/GetLogLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logLevelClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevelResponse, error) {
+	out := new(LogLevelResponse)
+	err := c.cc.Invoke(ctx, "/server.LogLevel/SetLogLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LogLevelServer is the server API for LogLevel service.
+type LogLevelServer interface {
+	GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevelResponse, error)
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevelResponse, error)
+}
+
+// UnimplementedLogLevelServer can be embedded to have forward compatible implementations.
+type UnimplementedLogLevelServer struct {
+}
+
+func (*UnimplementedLogLevelServer) GetLogLevel(ctx context.Context, req *GetLogLevelRequest) (*LogLevelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevel not implemented")
+}
+func (*UnimplementedLogLevelServer) SetLogLevel(ctx context.Context, req *SetLogLevelRequest) (*LogLevelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+
+func RegisterLogLevelServer(s *grpc.Server, srv LogLevelServer) {
+	s.RegisterService(&_LogLevel_serviceDesc, srv)
+}
+
+func _LogLevel_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/server.LogLevel/GetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServer).GetLogLevel(ctx, req.(*GetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogLevel_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/server.LogLevel/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _LogLevel_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "server.LogLevel",
+	HandlerType: (*LogLevelServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _LogLevel_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _LogLevel_SetLogLevel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "server/log_level.proto",
+}
+
+func (m *GetLogLevelRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetLogLevelRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *GetLogLevelRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *SetLogLevelRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SetLogLevelRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SetLogLevelRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.OrgID) > 0 {
+		i -= len(m.OrgID)
+		copy(dAtA[i:], m.OrgID)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.OrgID)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Component) > 0 {
+		i -= len(m.Component)
+		copy(dAtA[i:], m.Component)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.Component)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.TTL != nil {
+		n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(*m.TTL, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(*m.TTL):])
+		if err1 != nil {
+			return 0, err1
+		}
+		i -= n1
+		i = encodeVarintLogLevel(dAtA, i, uint64(n1))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Level) > 0 {
+		i -= len(m.Level)
+		copy(dAtA[i:], m.Level)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.Level)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *LogLevelResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LogLevelResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LogLevelResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Overrides) > 0 {
+		for iNdEx := len(m.Overrides) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Overrides[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintLogLevel(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if m.RevertAt != nil {
+		n2, err2 := github_com_gogo_protobuf_types.StdTimeMarshalTo(*m.RevertAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(*m.RevertAt):])
+		if err2 != nil {
+			return 0, err2
+		}
+		i -= n2
+		i = encodeVarintLogLevel(dAtA, i, uint64(n2))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Level) > 0 {
+		i -= len(m.Level)
+		copy(dAtA[i:], m.Level)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.Level)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *LogLevelOverride) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LogLevelOverride) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LogLevelOverride) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.RevertAt != nil {
+		n3, err3 := github_com_gogo_protobuf_types.StdTimeMarshalTo(*m.RevertAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(*m.RevertAt):])
+		if err3 != nil {
+			return 0, err3
+		}
+		i -= n3
+		i = encodeVarintLogLevel(dAtA, i, uint64(n3))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Level) > 0 {
+		i -= len(m.Level)
+		copy(dAtA[i:], m.Level)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.Level)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.OrgID) > 0 {
+		i -= len(m.OrgID)
+		copy(dAtA[i:], m.OrgID)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.OrgID)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Component) > 0 {
+		i -= len(m.Component)
+		copy(dAtA[i:], m.Component)
+		i = encodeVarintLogLevel(dAtA, i, uint64(len(m.Component)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintLogLevel(dAtA []byte, offset int, v uint64) int {
+	offset -= sovLogLevel(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *GetLogLevelRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *SetLogLevelRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Level)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	if m.TTL != nil {
+		l = github_com_gogo_protobuf_types.SizeOfStdDuration(*m.TTL)
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	l = len(m.Component)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	l = len(m.OrgID)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	return n
+}
+
+func (m *LogLevelResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Level)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	if m.RevertAt != nil {
+		l = github_com_gogo_protobuf_types.SizeOfStdTime(*m.RevertAt)
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	if len(m.Overrides) > 0 {
+		for _, e := range m.Overrides {
+			l = e.Size()
+			n += 1 + l + sovLogLevel(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LogLevelOverride) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Component)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	l = len(m.OrgID)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	l = len(m.Level)
+	if l > 0 {
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	if m.RevertAt != nil {
+		l = github_com_gogo_protobuf_types.SizeOfStdTime(*m.RevertAt)
+		n += 1 + l + sovLogLevel(uint64(l))
+	}
+	return n
+}
+
+func sovLogLevel(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozLogLevel(x uint64) (n int) {
+	return sovLogLevel(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *GetLogLevelRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&GetLogLevelRequest{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SetLogLevelRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SetLogLevelRequest{`,
+		`Level:` + fmt.Sprintf("%v", this.Level) + `,`,
+		`TTL:` + strings.Replace(fmt.Sprintf("%v", this.TTL), "Duration", "types.Duration", 1) + `,`,
+		`Component:` + fmt.Sprintf("%v", this.Component) + `,`,
+		`OrgID:` + fmt.Sprintf("%v", this.OrgID) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LogLevelResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForOverrides := "[]LogLevelOverride{"
+	for _, f := range this.Overrides {
+		repeatedStringForOverrides += strings.Replace(strings.Replace(f.String(), "LogLevelOverride", "LogLevelOverride", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForOverrides += "}"
+	s := strings.Join([]string{`&LogLevelResponse{`,
+		`Level:` + fmt.Sprintf("%v", this.Level) + `,`,
+		`RevertAt:` + strings.Replace(fmt.Sprintf("%v", this.RevertAt), "Timestamp", "types.Timestamp", 1) + `,`,
+		`Overrides:` + repeatedStringForOverrides + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LogLevelOverride) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LogLevelOverride{`,
+		`Component:` + fmt.Sprintf("%v", this.Component) + `,`,
+		`OrgID:` + fmt.Sprintf("%v", this.OrgID) + `,`,
+		`Level:` + fmt.Sprintf("%v", this.Level) + `,`,
+		`RevertAt:` + strings.Replace(fmt.Sprintf("%v", this.RevertAt), "Timestamp", "types.Timestamp", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringLogLevel(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *GetLogLevelRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogLevel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetLogLevelRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetLogLevelRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogLevel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SetLogLevelRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogLevel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SetLogLevelRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SetLogLevelRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Level = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TTL", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TTL == nil {
+				m.TTL = new(time.Duration)
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(m.TTL, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Component", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Component = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OrgID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OrgID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogLevel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LogLevelResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogLevel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LogLevelResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LogLevelResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Level = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RevertAt", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RevertAt == nil {
+				m.RevertAt = new(time.Time)
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(m.RevertAt, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Overrides", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Overrides = append(m.Overrides, LogLevelOverride{})
+			if err := m.Overrides[len(m.Overrides)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogLevel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LogLevelOverride) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogLevel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LogLevelOverride: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LogLevelOverride: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Component", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Component = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OrgID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OrgID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Level = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RevertAt", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RevertAt == nil {
+				m.RevertAt = new(time.Time)
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(m.RevertAt, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogLevel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthLogLevel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipLogLevel(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowLogLevel
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowLogLevel
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthLogLevel
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupLogLevel
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthLogLevel
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthLogLevel        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowLogLevel          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupLogLevel = fmt.Errorf("proto: unexpected end of group")
+)
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/weaveworks/common/logging"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	var info logging.Level
+	require.NoError(t, info.Set("info"))
+	level := logging.NewDynamicLevel(info)
+	router := mux.NewRouter()
+	RegisterLogLevel(router, level, 0, logging.Noop())
+
+	do := func(method string, form url.Values) (int, LogLevelStatus) {
+		rec := httptest.NewRecorder()
+		var req *http.Request
+		if method == "DELETE" {
+			// Only the query is parsed for DELETE.
+			req = httptest.NewRequest(method, "/log_level?"+form.Encode(), nil)
+		} else {
+			req = httptest.NewRequest(method, "/log_level", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		router.ServeHTTP(rec, req)
+		var s LogLevelStatus
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		}
+		return rec.Code, s
+	}
+
+	code, s := do("GET", nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, LogLevelStatus{Level: "info"}, s)
+
+	code, _ = do("PUT", url.Values{"level": {"verbose"}})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = do("PUT", url.Values{"level": {"debug"}, "ttl": {"soon"}})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = do("POST", url.Values{"level": {"debug"}})
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, s = do("PUT", url.Values{"level": {"warn"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, LogLevelStatus{Level: "warn"}, s)
+
+	// A level set with a TTL reverts to the one before.
+	code, s = do("PUT", url.Values{"level": {"debug"}, "ttl": {"100ms"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "debug", s.Level)
+	require.NotNil(t, s.RevertAt)
+	require.Eventually(t, func() bool {
+		_, s = do("GET", nil)
+		return s.Level == "warn" && s.RevertAt == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// Overrides are for a component or an org, and can be deleted.
+	code, _ = do("PUT", url.Values{"level": {"debug"}, "component": {"grpc"}, "org_id": {"1"}})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = do("DELETE", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = do("PUT", url.Values{"level": {"debug"}, "component": {"grpc"}})
+	require.Equal(t, http.StatusOK, code)
+	code, s = do("PUT", url.Values{"level": {"error"}, "org_id": {"1"}, "ttl": {"1h"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "warn", s.Level)
+	require.Len(t, s.Overrides, 2)
+	require.Equal(t, LogLevelOverride{Component: "grpc", Level: "debug"}, s.Overrides[0])
+	require.Equal(t, "1", s.Overrides[1].OrgID)
+	require.Equal(t, "error", s.Overrides[1].Level)
+	require.NotNil(t, s.Overrides[1].RevertAt)
+	code, s = do("DELETE", url.Values{"component": {"grpc"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, s.Overrides, 1)
+	require.Equal(t, "1", s.Overrides[0].OrgID)
+}
+
+func TestLogLevelGRPC(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.HTTPListenPort = 9202
+	cfg.GRPCListenAddress = "localhost"
+	cfg.GRPCListenPort = 9203
+	cfg.MetricsNamespace = "testing_log_level"
+	cfg.RegisterLogLevelEndpoint = true
+	cfg.LogLevelDefaultTTL = time.Hour
+	require.NoError(t, cfg.LogLevel.Set("info"))
+	server, err := New(cfg)
+	require.NoError(t, err)
+	go server.Run()
+	defer server.Shutdown()
+
+	conn, err := grpc.Dial("localhost:9203", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewLogLevelClient(conn)
+
+	resp, err := client.GetLogLevel(context.Background(), &GetLogLevelRequest{})
+	require.NoError(t, err)
+	require.Equal(t, &LogLevelResponse{Level: "info"}, resp)
+
+	_, err = client.SetLogLevel(context.Background(), &SetLogLevelRequest{Level: "verbose"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Without a TTL, the default applies.
+	resp, err = client.SetLogLevel(context.Background(), &SetLogLevelRequest{Level: "debug"})
+	require.NoError(t, err)
+	require.Equal(t, "debug", resp.Level)
+	require.NotNil(t, resp.RevertAt)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *resp.RevertAt, time.Minute)
+	l := server.LogLevel.Level()
+	require.Equal(t, "debug", l.String())
+
+	resp, err = client.SetLogLevel(context.Background(), &SetLogLevelRequest{Level: "warn", OrgID: "noisy"})
+	require.NoError(t, err)
+	require.Len(t, resp.Overrides, 1)
+	require.Equal(t, "noisy", resp.Overrides[0].OrgID)
+	require.Equal(t, "warn", resp.Overrides[0].Level)
+	resp, err = client.SetLogLevel(context.Background(), &SetLogLevelRequest{OrgID: "noisy"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Overrides)
+
+	// The HTTP endpoint changes the same level.
+	res, err := http.Get("http://127.0.0.1:9202/log_level")
+	require.NoError(t, err)
+	var s LogLevelStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&s))
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "debug", s.Level)
+}
+
+func TestLogLevelCustomLog(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.RegisterLogLevelEndpoint = true
+	cfg.Log = logging.Noop()
+	cfg.Registerer = prometheus.NewRegistry()
+	_, err := New(cfg)
+	require.EqualError(t, err, "the log level endpoint can't change the level of a custom Log unless DynamicLogLevel is set")
+
+	cfg.RegisterLogLevelEndpoint = false
+	var debug logging.Level
+	require.NoError(t, debug.Set("debug"))
+	cfg.LogLevelOverrides.Components = map[string]logging.Level{"grpc": debug}
+	cfg.Registerer = prometheus.NewRegistry()
+	_, err = New(cfg)
+	require.EqualError(t, err, "log level overrides can't apply to a custom Log unless DynamicLogLevel is set")
+}
+
+func TestDefaultLoggerFormat(t *testing.T) {
+	for _, tc := range []struct {
+		flags  []string
+		prefix string
+	}{
+		// Without the flag, the output is logrus's text format, as it always was.
+		{nil, "time="},
+		{[]string{"-log.format=logfmt"}, "ts="},
+		{[]string{"-log.format=json"}, "{"},
+	} {
+		var cfg Config
+		fs := flag.NewFlagSet("", flag.PanicOnError)
+		cfg.RegisterFlags(fs)
+		require.NoError(t, fs.Parse(tc.flags))
+
+		// logrus writes to whatever os.Stderr is when the logger is made.
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stderr := os.Stderr
+		os.Stderr = w
+		logger := defaultLogger(cfg.LogFormat, logging.NewDynamicLevel(cfg.LogLevel))
+		os.Stderr = stderr
+
+		logger.Infoln("hello")
+		w.Close()
+		out, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(out), tc.prefix), "%v: %s", tc.flags, out)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/common/instrument"
+	"github.com/weaveworks/common/logging"
 	"github.com/weaveworks/common/middleware"
 	"time"
 )
@@ -14,6 +15,17 @@ type Metrics struct {
 	ReceivedMessageSize *prometheus.HistogramVec
 	SentMessageSize     *prometheus.HistogramVec
 	InflightRequests    *prometheus.GaugeVec
+
+	TLSCertificateReloads *prometheus.CounterVec
+	TLSCertificateExpiry  *prometheus.GaugeVec
+
+	ConcurrencyLimit         *prometheus.GaugeVec
+	ConcurrencyLimitRejected *prometheus.CounterVec
+
+	HTTPGRPCUncompressedBytes *prometheus.CounterVec
+	HTTPGRPCCompressedBytes   *prometheus.CounterVec
+
+	LogMessagesSuppressed *prometheus.CounterVec
 }
 
 func NewServerMetrics(cfg Config) *Metrics {
@@ -54,6 +66,37 @@ func NewServerMetrics(cfg Config) *Metrics {
 			Name:      "inflight_requests",
 			Help:      "Current number of inflight requests.",
 		}, []string{"method", "route"}),
+		TLSCertificateReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "tls_certificate_reloads_total",
+			Help:      "Total number of TLS certificate reloads, by result.",
+		}, []string{"protocol", "result"}),
+		TLSCertificateExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "tls_certificate_expiry_timestamp_seconds",
+			Help:      "Expiry time of the currently loaded TLS certificate, as a Unix timestamp.",
+		}, []string{"protocol"}),
+		ConcurrencyLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "concurrency_limit",
+			Help:      "Current limit on the number of requests in flight.",
+		}, []string{"protocol"}),
+		ConcurrencyLimitRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "concurrency_limit_rejected_requests_total",
+			Help:      "Total number of requests rejected because the concurrency limit was reached.",
+		}, []string{"protocol"}),
+		HTTPGRPCUncompressedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "httpgrpc_compression_uncompressed_bytes_total",
+			Help:      "Total size (in bytes) of HTTP-over-gRPC response bodies before compression, by encoding.",
+		}, []string{"encoding"}),
+		HTTPGRPCCompressedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.MetricsNamespace,
+			Name:      "httpgrpc_compression_compressed_bytes_total",
+			Help:      "Total size (in bytes) of HTTP-over-gRPC response bodies after compression, by encoding.",
+		}, []string{"encoding"}),
+		LogMessagesSuppressed: logging.NewSuppressedCounter(cfg.MetricsNamespace),
 	}
 }
 
@@ -65,5 +108,12 @@ func (s *Metrics) MustRegister(registerer prometheus.Registerer) {
 		s.ReceivedMessageSize,
 		s.SentMessageSize,
 		s.InflightRequests,
+		s.TLSCertificateReloads,
+		s.TLSCertificateExpiry,
+		s.ConcurrencyLimit,
+		s.ConcurrencyLimitRejected,
+		s.HTTPGRPCUncompressedBytes,
+		s.HTTPGRPCCompressedBytes,
+		s.LogMessagesSuppressed,
 	)
 }
@@ -2,13 +2,17 @@ package server
 
 import (
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"math"
 	"net"
 	"net/http"
 	_ "net/http/pprof" // anonymous import to get the pprof handler registered
+	"os"
+	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -22,6 +26,7 @@ import (
 	"golang.org/x/net/netutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 
 	"github.com/weaveworks/common/httpgrpc"
@@ -75,17 +80,36 @@ type Config struct {
 	GRPCListenAddress string `yaml:"grpc_listen_address"`
 	GRPCListenPort    int    `yaml:"grpc_listen_port"`
 	GRPCConnLimit     int    `yaml:"grpc_listen_conn_limit"`
+	UnixSocketMode    uint   `yaml:"unix_socket_mode"`
+
+	HTTPInflightLimit                 int           `yaml:"http_inflight_limit"`
+	GRPCInflightLimit                 int           `yaml:"grpc_inflight_limit"`
+	InflightLimitMode                 string        `yaml:"inflight_limit_mode"`
+	InflightLimitAIMDLatencyThreshold time.Duration `yaml:"inflight_limit_aimd_latency_threshold"`
+
+	GRPCLegacyHTTPStatusCodes bool `yaml:"grpc_legacy_http_status_codes"`
+	HTTPGRPCTrustedProxyTLS   bool `yaml:"httpgrpc_trusted_proxy_tls"`
+
+	TraceTenantBaggage     bool `yaml:"trace_tenant_baggage"`
+	TraceTenantFromBaggage bool `yaml:"trace_tenant_from_baggage"`
 
 	CipherSuites  string    `yaml:"tls_cipher_suites"`
 	MinVersion    string    `yaml:"tls_min_version"`
 	HTTPTLSConfig TLSConfig `yaml:"http_tls_config"`
 	GRPCTLSConfig TLSConfig `yaml:"grpc_tls_config"`
 
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval"`
+
 	RegisterInstrumentation  bool `yaml:"register_instrumentation"`
+	RegisterHealthEndpoints  bool `yaml:"register_health_endpoints"`
+	RegisterLogLevelEndpoint bool `yaml:"register_log_level_endpoint"`
 	ExcludeRequestInLog      bool `yaml:"-"`
 	DisableRequestSuccessLog bool `yaml:"-"`
 
 	ServerGracefulShutdownTimeout time.Duration `yaml:"graceful_shutdown_timeout"`
+	ServerPreStopDelay            time.Duration `yaml:"pre_stop_delay"`
+	GracefulUpgrade               bool          `yaml:"graceful_upgrade_enabled"`
+	GracefulUpgradeTimeout        time.Duration `yaml:"graceful_upgrade_timeout"`
 	HTTPServerReadTimeout         time.Duration `yaml:"http_server_read_timeout"`
 	HTTPServerWriteTimeout        time.Duration `yaml:"http_server_write_timeout"`
 	HTTPServerIdleTimeout         time.Duration `yaml:"http_server_idle_timeout"`
@@ -109,15 +133,19 @@ type Config struct {
 	GRPCServerMinTimeBetweenPings      time.Duration `yaml:"grpc_server_min_time_between_pings"`
 	GRPCServerPingWithoutStreamAllowed bool          `yaml:"grpc_server_ping_without_stream_allowed"`
 
-	LogFormat                    logging.Format    `yaml:"log_format"`
-	LogLevel                     logging.Level     `yaml:"log_level"`
-	Log                          logging.Interface `yaml:"-"`
-	LogSourceIPs                 bool              `yaml:"log_source_ips_enabled"`
-	LogSourceIPsHeader           string            `yaml:"log_source_ips_header"`
-	LogSourceIPsRegex            string            `yaml:"log_source_ips_regex"`
-	LogRequestHeaders            bool              `yaml:"log_request_headers"`
-	LogRequestAtInfoLevel        bool              `yaml:"log_request_at_info_level_enabled"`
-	LogRequestExcludeHeadersList string            `yaml:"log_request_exclude_headers_list"`
+	LogFormat                    logging.Format         `yaml:"log_format"`
+	LogLevel                     logging.Level          `yaml:"log_level"`
+	LogLevelDefaultTTL           time.Duration          `yaml:"log_level_default_ttl"`
+	LogLevelOverrides            logging.LevelOverrides `yaml:"log_level_overrides"`
+	LogSampling                  logging.SamplingConfig `yaml:"log_sampling"`
+	DynamicLogLevel              *logging.DynamicLevel  `yaml:"-"` // If set, used instead of LogLevel, so Log can consult it.
+	Log                          logging.Interface      `yaml:"-"`
+	LogSourceIPs                 bool                   `yaml:"log_source_ips_enabled"`
+	LogSourceIPsHeader           string                 `yaml:"log_source_ips_header"`
+	LogSourceIPsRegex            string                 `yaml:"log_source_ips_regex"`
+	LogRequestHeaders            bool                   `yaml:"log_request_headers"`
+	LogRequestAtInfoLevel        bool                   `yaml:"log_request_at_info_level_enabled"`
+	LogRequestExcludeHeadersList string                 `yaml:"log_request_exclude_headers_list"`
 
 	// If not set, default signal handler is used.
 	SignalHandler SignalHandler `yaml:"-"`
@@ -134,7 +162,7 @@ var infinty = time.Duration(math.MaxInt64)
 // RegisterFlags adds the flags required to config this to the given FlagSet
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.HTTPListenAddress, "server.http-listen-address", "", "HTTP server listen address.")
-	f.StringVar(&cfg.HTTPListenNetwork, "server.http-listen-network", DefaultNetwork, "HTTP server listen network, default tcp")
+	f.StringVar(&cfg.HTTPListenNetwork, "server.http-listen-network", DefaultNetwork, "HTTP server listen network, default tcp. Use unix to listen on the socket path given as the listen address, or systemd to use a socket passed by socket activation.")
 	f.StringVar(&cfg.CipherSuites, "server.tls-cipher-suites", "", "Comma-separated list of cipher suites to use. If blank, the default Go cipher suites is used.")
 	f.StringVar(&cfg.MinVersion, "server.tls-min-version", "", "Minimum TLS version to use. Allowed values: VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13. If blank, the Go TLS minimum version is used.")
 	f.StringVar(&cfg.HTTPTLSConfig.TLSCertPath, "server.http-tls-cert-path", "", "HTTP server cert path.")
@@ -145,14 +173,29 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.GRPCTLSConfig.TLSKeyPath, "server.grpc-tls-key-path", "", "GRPC TLS server key path.")
 	f.StringVar(&cfg.GRPCTLSConfig.ClientAuth, "server.grpc-tls-client-auth", "", "GRPC TLS Client Auth type.")
 	f.StringVar(&cfg.GRPCTLSConfig.ClientCAs, "server.grpc-tls-ca-path", "", "GRPC TLS Client CA path.")
+	f.DurationVar(&cfg.TLSReloadInterval, "server.tls-reload-interval", time.Minute, "How often to check the TLS certificate, key and client CA files for changes, and reload them. 0 to disable.")
 	f.IntVar(&cfg.HTTPListenPort, "server.http-listen-port", 80, "HTTP server listen port.")
 	f.IntVar(&cfg.HTTPConnLimit, "server.http-conn-limit", 0, "Maximum number of simultaneous http connections, <=0 to disable")
-	f.StringVar(&cfg.GRPCListenNetwork, "server.grpc-listen-network", DefaultNetwork, "gRPC server listen network")
+	f.StringVar(&cfg.GRPCListenNetwork, "server.grpc-listen-network", DefaultNetwork, "gRPC server listen network. Use unix to listen on the socket path given as the listen address, or systemd to use a socket passed by socket activation.")
+	f.UintVar(&cfg.UnixSocketMode, "server.unix-socket-mode", 0660, "File permissions for unix sockets created by the server.")
 	f.StringVar(&cfg.GRPCListenAddress, "server.grpc-listen-address", "", "gRPC server listen address.")
 	f.IntVar(&cfg.GRPCListenPort, "server.grpc-listen-port", 9095, "gRPC server listen port.")
 	f.IntVar(&cfg.GRPCConnLimit, "server.grpc-conn-limit", 0, "Maximum number of simultaneous grpc connections, <=0 to disable")
+	f.IntVar(&cfg.HTTPInflightLimit, "server.http-inflight-limit", 0, "Maximum number of HTTP requests in flight; further requests are rejected with 503. <=0 to disable.")
+	f.IntVar(&cfg.GRPCInflightLimit, "server.grpc-inflight-limit", 0, "Maximum number of gRPC requests in flight; further requests are rejected with Unavailable. <=0 to disable.")
+	f.StringVar(&cfg.InflightLimitMode, "server.inflight-limit-mode", middleware.ConcurrencyLimitFixed, "How the in-flight limits adapt to observed latency: fixed, aimd or gradient. In the adaptive modes the configured limits are the maximum.")
+	f.DurationVar(&cfg.InflightLimitAIMDLatencyThreshold, "server.inflight-limit-aimd-latency-threshold", time.Second, "In aimd mode, requests slower than this shrink the in-flight limit.")
+	f.BoolVar(&cfg.HTTPGRPCTrustedProxyTLS, "server.httpgrpc-trusted-proxy-tls", false, "Pass on the TLS state, including client certificates, sent with HTTP requests over gRPC to handlers. Clients can send any state, so only enable this if every gRPC client is a trusted proxy.")
+	f.BoolVar(&cfg.GRPCLegacyHTTPStatusCodes, "server.grpc-legacy-http-status-codes", false, "Return httpgrpc errors with the HTTP status code as the gRPC code, for clients which depend on it, instead of the equivalent gRPC code.")
+	f.BoolVar(&cfg.TraceTenantBaggage, "server.trace-tenant-baggage", false, "Put the org and user IDs of requests in tracing baggage, so they are sent on with the trace to other services.")
+	f.BoolVar(&cfg.TraceTenantFromBaggage, "server.trace-tenant-from-baggage", false, "Take the org and user IDs from tracing baggage for requests which don't have them in headers. Only enable this for services which trust their callers as they would with the headers.")
 	f.BoolVar(&cfg.RegisterInstrumentation, "server.register-instrumentation", true, "Register the intrumentation handlers (/metrics etc).")
+	f.BoolVar(&cfg.RegisterHealthEndpoints, "server.register-health-endpoints", false, "Register the /ready and /healthz handlers, and the gRPC health service.")
+	f.BoolVar(&cfg.RegisterLogLevelEndpoint, "server.register-log-level-endpoint", false, "Register the /log_level handler and the gRPC LogLevel service, to change the log level at runtime.")
 	f.DurationVar(&cfg.ServerGracefulShutdownTimeout, "server.graceful-shutdown-timeout", 30*time.Second, "Timeout for graceful shutdowns")
+	f.DurationVar(&cfg.ServerPreStopDelay, "server.pre-stop-delay", 0, "How long to keep serving after being marked as not ready during shutdown, to give load balancers time to stop sending requests.")
+	f.BoolVar(&cfg.GracefulUpgrade, "server.graceful-upgrade-enabled", false, "On SIGUSR2, start a new copy of the binary and hand it the listeners, then shut down once it is ready.")
+	f.DurationVar(&cfg.GracefulUpgradeTimeout, "server.graceful-upgrade-timeout", time.Minute, "How long to wait for the new process to become ready during a graceful upgrade.")
 	f.DurationVar(&cfg.HTTPServerReadTimeout, "server.http-read-timeout", 30*time.Second, "Read timeout for HTTP server")
 	f.DurationVar(&cfg.HTTPServerWriteTimeout, "server.http-write-timeout", 30*time.Second, "Write timeout for HTTP server")
 	f.DurationVar(&cfg.HTTPServerIdleTimeout, "server.http-idle-timeout", 120*time.Second, "Idle timeout for HTTP server")
@@ -169,6 +212,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.PathPrefix, "server.path-prefix", "", "Base path to serve all API routes from (e.g. /v1/)")
 	cfg.LogFormat.RegisterFlags(f)
 	cfg.LogLevel.RegisterFlags(f)
+	cfg.LogSampling.RegisterFlags(f)
+	f.DurationVar(&cfg.LogLevelDefaultTTL, "server.log-level-default-ttl", 0, "How long a log level set at runtime lasts before reverting, unless the request says. 0 to keep it until it is changed again.")
 	f.BoolVar(&cfg.LogSourceIPs, "server.log-source-ips-enabled", false, "Optionally log the source IPs.")
 	f.StringVar(&cfg.LogSourceIPsHeader, "server.log-source-ips-header", "", "Header field storing the source IPs. Only used if server.log-source-ips-enabled is true. If not set the default Forwarded, X-Real-IP and X-Forwarded-For headers are used")
 	f.StringVar(&cfg.LogSourceIPsRegex, "server.log-source-ips-regex", "", "Regex for matching the source IPs. Only used if server.log-source-ips-enabled is true. If not set the default Forwarded, X-Real-IP and X-Forwarded-For headers are used")
@@ -190,10 +235,19 @@ func (cfg *Config) registererOrDefault() prometheus.Registerer {
 // Servers will be automatically instrumented for Prometheus metrics.
 type Server struct {
 	cfg          Config
+	metrics      *Metrics
 	handler      SignalHandler
 	grpcListener net.Listener
 	httpListener net.Listener
 
+	// The listeners before any wrapping, which can be handed over by Upgrade.
+	grpcRawListener net.Listener
+	httpRawListener net.Listener
+	upgradeMtx      sync.Mutex
+	// Set if this process was started by Upgrade, to report when it is serving.
+	upgradeReady *os.File
+	serving      []*servingListener
+
 	// These fields are used to support grpc over the http server
 	//  if RouteHTTPToGRPC is set. the fields are kept here
 	//  so they can be initialized in New() and started in Run()
@@ -201,10 +255,16 @@ type Server struct {
 	grpcOnHTTPListener net.Listener
 	GRPCOnHTTPServer   *grpc.Server
 
+	// Watch the TLS certificates for changes while the server is running.
+	tlsReloaders []*certReloader
+	logSampler   *logging.Sampled
+
 	HTTP       *mux.Router
 	HTTPServer *http.Server
 	GRPC       *grpc.Server
+	Health     *Health
 	Log        logging.Interface
+	LogLevel   *logging.DynamicLevel
 	Registerer prometheus.Registerer
 	Gatherer   prometheus.Gatherer
 }
@@ -223,11 +283,36 @@ func NewWithMetrics(cfg Config, metrics *Metrics) (*Server, error) {
 }
 
 func newServer(cfg Config, metrics *Metrics) (*Server, error) {
+	if cfg.GracefulUpgrade && cfg.SignalHandler != nil {
+		return nil, errors.New("graceful upgrade on SIGUSR2 needs the default signal handler; a custom SignalHandler can call Server.Upgrade itself")
+	}
+	// The level a user-supplied logger logs at can only be changed through
+	// the DynamicLevel it consults.
+	if cfg.Log != nil && cfg.DynamicLogLevel == nil {
+		if cfg.RegisterLogLevelEndpoint {
+			return nil, errors.New("the log level endpoint can't change the level of a custom Log unless DynamicLogLevel is set")
+		}
+		if len(cfg.LogLevelOverrides.Components) > 0 || len(cfg.LogLevelOverrides.Orgs) > 0 {
+			return nil, errors.New("log level overrides can't apply to a custom Log unless DynamicLogLevel is set")
+		}
+	}
+
 	// If user doesn't supply a logging implementation, by default instantiate
-	// logrus.
+	// logrus, with a level which can be changed at runtime. A user-supplied
+	// logger can consult cfg.DynamicLogLevel to do the same.
+	logLevel := cfg.DynamicLogLevel
+	if logLevel == nil {
+		logLevel = logging.NewDynamicLevel(cfg.LogLevel)
+	}
+	logLevel.SetOverrides(cfg.LogLevelOverrides)
 	log := cfg.Log
 	if log == nil {
-		log = logging.NewLogrus(cfg.LogLevel)
+		log = defaultLogger(cfg.LogFormat, logLevel)
+	}
+	var logSampler *logging.Sampled
+	if cfg.LogSampling.Enabled() {
+		logSampler = logging.NewSampled(cfg.LogSampling, log, metrics.LogMessagesSuppressed)
+		log = logSampler
 	}
 
 	gatherer := cfg.Gatherer
@@ -235,15 +320,20 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		gatherer = prometheus.DefaultGatherer
 	}
 
-	network := cfg.HTTPListenNetwork
-	if network == "" {
-		network = DefaultNetwork
-	}
 	// Setup listeners first, so we can fail early if the port is in use.
-	httpListener, err := net.Listen(network, fmt.Sprintf("%s:%d", cfg.HTTPListenAddress, cfg.HTTPListenPort))
+	// If we were started by Upgrade, use the listeners handed over by the old process.
+	inherited, upgradeReadyFile, err := inheritedListeners()
 	if err != nil {
 		return nil, err
 	}
+	httpListener, ok := inherited[upgradeHTTP]
+	if !ok {
+		httpListener, err = listen(cfg.HTTPListenNetwork, cfg.HTTPListenAddress, cfg.HTTPListenPort, os.FileMode(cfg.UnixSocketMode), 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	httpRawListener := httpListener
 	httpListener = middleware.CountingListener(httpListener, metrics.TcpConnections.WithLabelValues("http"))
 
 	metrics.TcpConnectionsLimit.WithLabelValues("http").Set(float64(cfg.HTTPConnLimit))
@@ -260,14 +350,14 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		grpcOnHTTPListener = grpchttpmux.Match(cmux.HTTP2())
 	}
 
-	network = cfg.GRPCListenNetwork
-	if network == "" {
-		network = DefaultNetwork
-	}
-	grpcListener, err := net.Listen(network, fmt.Sprintf("%s:%d", cfg.GRPCListenAddress, cfg.GRPCListenPort))
-	if err != nil {
-		return nil, err
+	grpcListener, ok := inherited[upgradeGRPC]
+	if !ok {
+		grpcListener, err = listen(cfg.GRPCListenNetwork, cfg.GRPCListenAddress, cfg.GRPCListenPort, os.FileMode(cfg.UnixSocketMode), 1)
+		if err != nil {
+			return nil, err
+		}
 	}
+	grpcRawListener := grpcListener
 	grpcListener = middleware.CountingListener(grpcListener, metrics.TcpConnections.WithLabelValues("grpc"))
 
 	metrics.TcpConnectionsLimit.WithLabelValues("grpc").Set(float64(cfg.GRPCConnLimit))
@@ -275,6 +365,21 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		grpcListener = netutil.LimitListener(grpcListener, cfg.GRPCConnLimit)
 	}
 
+	// If we were started by Upgrade, only report we are ready once every
+	// server is accepting connections.
+	var serving []*servingListener
+	if upgradeReadyFile != nil {
+		watch := func(l net.Listener) net.Listener {
+			sl := newServingListener(l)
+			serving = append(serving, sl)
+			return sl
+		}
+		httpListener, grpcListener = watch(httpListener), watch(grpcListener)
+		if grpcOnHTTPListener != nil {
+			grpcOnHTTPListener = watch(grpcOnHTTPListener)
+		}
+	}
+
 	cipherSuites, err := stringToCipherSuites(cfg.CipherSuites)
 	if err != nil {
 		return nil, err
@@ -285,6 +390,7 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 	}
 
 	// Setup TLS
+	var tlsReloaders []*certReloader
 	var httpTLSConfig *tls.Config
 	if len(cfg.HTTPTLSConfig.TLSCertPath) > 0 && len(cfg.HTTPTLSConfig.TLSKeyPath) > 0 {
 		// Note: ConfigToTLSConfig from prometheus/exporter-toolkit is awaiting security review.
@@ -299,6 +405,12 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error generating http tls config: %v", err)
 		}
+		httpTLSReloader, err := newCertReloader("http", cfg.HTTPTLSConfig, httpTLSConfig, []string{"h2", "http/1.1"}, cfg.TLSReloadInterval, log, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("error loading http tls certificate: %v", err)
+		}
+		httpTLSConfig = httpTLSReloader.TLSConfig()
+		tlsReloaders = append(tlsReloaders, httpTLSReloader)
 	}
 	var grpcTLSConfig *tls.Config
 	if len(cfg.GRPCTLSConfig.TLSCertPath) > 0 && len(cfg.GRPCTLSConfig.TLSKeyPath) > 0 {
@@ -314,29 +426,63 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error generating grpc tls config: %v", err)
 		}
+		grpcTLSReloader, err := newCertReloader("grpc", cfg.GRPCTLSConfig, grpcTLSConfig, []string{"h2"}, cfg.TLSReloadInterval, log, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("error loading grpc tls certificate: %v", err)
+		}
+		grpcTLSConfig = grpcTLSReloader.TLSConfig()
+		tlsReloaders = append(tlsReloaders, grpcTLSReloader)
 	}
 
 	log.WithField("http", httpListener.Addr()).WithField("grpc", grpcListener.Addr()).Infof("server listening on addresses")
 
 	// Setup gRPC server
 	serverLog := middleware.GRPCServerLog{
-		Log:                      log,
+		Log:                      log.WithField(logging.ComponentField, "grpc"),
 		WithRequest:              !cfg.ExcludeRequestInLog,
 		DisableRequestSuccessLog: cfg.DisableRequestSuccessLog,
 	}
+	tenantTracer := middleware.GRPCTenantTracer{
+		Baggage:     cfg.TraceTenantBaggage,
+		FromBaggage: cfg.TraceTenantFromBaggage,
+	}
 	grpcMiddleware := []grpc.UnaryServerInterceptor{
 		serverLog.UnaryServerInterceptor,
 		otgrpc.OpenTracingServerInterceptor(opentracing.GlobalTracer()),
+		tenantTracer.UnaryServerInterceptor,
+		middleware.UnaryServerRouteTagInterceptor,
 		middleware.UnaryServerInstrumentInterceptor(metrics.RequestDuration),
 	}
-	grpcMiddleware = append(grpcMiddleware, cfg.GRPCMiddleware...)
-
 	grpcStreamMiddleware := []grpc.StreamServerInterceptor{
 		serverLog.StreamServerInterceptor,
 		otgrpc.OpenTracingStreamServerInterceptor(opentracing.GlobalTracer()),
+		tenantTracer.StreamServerInterceptor,
+		middleware.StreamServerRouteTagInterceptor,
 		middleware.StreamServerInstrumentInterceptor(metrics.RequestDuration),
 	}
+	if cfg.GRPCInflightLimit > 0 {
+		limiter, err := middleware.NewConcurrencyLimiter(cfg.InflightLimitMode, cfg.GRPCInflightLimit, cfg.InflightLimitAIMDLatencyThreshold, metrics.ConcurrencyLimit.WithLabelValues("grpc"))
+		if err != nil {
+			return nil, err
+		}
+		concurrencyLimit := middleware.GRPCConcurrencyLimit{
+			Limiter:  limiter,
+			Rejected: metrics.ConcurrencyLimitRejected.WithLabelValues("grpc"),
+			// Keep health checks working when the server is busy.
+			ExemptMethods: []string{"/grpc.health.v1.Health/Check", "/grpc.health.v1.Health/Watch"},
+			// Each HandleStream call is an HTTP request, so its latency counts.
+			RequestStreams: []string{"/httpgrpc.HTTPStream/HandleStream"},
+		}
+		grpcMiddleware = append(grpcMiddleware, concurrencyLimit.UnaryServerInterceptor)
+		grpcStreamMiddleware = append(grpcStreamMiddleware, concurrencyLimit.StreamServerInterceptor)
+	}
+	grpcMiddleware = append(grpcMiddleware, cfg.GRPCMiddleware...)
 	grpcStreamMiddleware = append(grpcStreamMiddleware, cfg.GRPCStreamMiddleware...)
+	if cfg.GRPCLegacyHTTPStatusCodes {
+		// Outermost, so errors from every other interceptor are converted too.
+		grpcMiddleware = append([]grpc.UnaryServerInterceptor{middleware.UnaryServerLegacyHTTPCodesInterceptor}, grpcMiddleware...)
+		grpcStreamMiddleware = append([]grpc.StreamServerInterceptor{middleware.StreamServerLegacyHTTPCodesInterceptor}, grpcStreamMiddleware...)
+	}
 
 	grpcKeepAliveOptions := keepalive.ServerParameters{
 		MaxConnectionIdle:     cfg.GRPCServerMaxConnectionIdle,
@@ -389,6 +535,19 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		RegisterInstrumentationWithGatherer(router, gatherer)
 	}
 
+	health := NewHealth()
+	if cfg.RegisterHealthEndpoints {
+		RegisterHealth(router, health)
+		grpc_health_v1.RegisterHealthServer(grpcServer, health)
+		grpc_health_v1.RegisterHealthServer(grpcOnHttpServer, health)
+	}
+	if cfg.RegisterLogLevelEndpoint {
+		RegisterLogLevel(router, logLevel, cfg.LogLevelDefaultTTL, log)
+		logLevelServer := NewLogLevelServer(logLevel, cfg.LogLevelDefaultTTL, log)
+		RegisterLogLevelServer(grpcServer, logLevelServer)
+		RegisterLogLevelServer(grpcOnHttpServer, logLevelServer)
+	}
+
 	var sourceIPs *middleware.SourceIPExtractor
 	if cfg.LogSourceIPs {
 		sourceIPs, err = middleware.NewSourceIPs(cfg.LogSourceIPsHeader, cfg.LogSourceIPsRegex)
@@ -397,13 +556,15 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		}
 	}
 
-	defaultLogMiddleware := middleware.NewLogMiddleware(log, cfg.LogRequestHeaders, cfg.LogRequestAtInfoLevel, sourceIPs, strings.Split(cfg.LogRequestExcludeHeadersList, ","))
+	defaultLogMiddleware := middleware.NewLogMiddleware(log.WithField(logging.ComponentField, "http"), cfg.LogRequestHeaders, cfg.LogRequestAtInfoLevel, sourceIPs, strings.Split(cfg.LogRequestExcludeHeadersList, ","))
 	defaultLogMiddleware.DisableRequestSuccessLog = cfg.DisableRequestSuccessLog
 
 	defaultHTTPMiddleware := []middleware.Interface{
 		middleware.Tracer{
-			RouteMatcher: router,
-			SourceIPs:    sourceIPs,
+			RouteMatcher:      router,
+			SourceIPs:         sourceIPs,
+			TenantBaggage:     cfg.TraceTenantBaggage,
+			TenantFromBaggage: cfg.TraceTenantFromBaggage,
 		},
 		defaultLogMiddleware,
 		middleware.Instrument{
@@ -415,11 +576,23 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		},
 	}
 	var httpMiddleware []middleware.Interface
-	if cfg.DoNotAddDefaultHTTPMiddleware {
-		httpMiddleware = cfg.HTTPMiddleware
-	} else {
-		httpMiddleware = append(defaultHTTPMiddleware, cfg.HTTPMiddleware...)
+	if !cfg.DoNotAddDefaultHTTPMiddleware {
+		httpMiddleware = append(httpMiddleware, defaultHTTPMiddleware...)
 	}
+	if cfg.HTTPInflightLimit > 0 {
+		limiter, err := middleware.NewConcurrencyLimiter(cfg.InflightLimitMode, cfg.HTTPInflightLimit, cfg.InflightLimitAIMDLatencyThreshold, metrics.ConcurrencyLimit.WithLabelValues("http"))
+		if err != nil {
+			return nil, err
+		}
+		concurrencyLimit := middleware.ConcurrencyLimit{
+			Limiter:  limiter,
+			Rejected: metrics.ConcurrencyLimitRejected.WithLabelValues("http"),
+			// Keep health checks and scrapes working when the server is busy.
+			ExemptPaths: []string{path.Join(cfg.PathPrefix, "/ready"), path.Join(cfg.PathPrefix, "/healthz"), path.Join(cfg.PathPrefix, "/metrics")},
+		}
+		httpMiddleware = append(httpMiddleware, concurrencyLimit)
+	}
+	httpMiddleware = append(httpMiddleware, cfg.HTTPMiddleware...)
 
 	httpServer := &http.Server{
 		ReadTimeout:  cfg.HTTPServerReadTimeout,
@@ -431,27 +604,50 @@ func newServer(cfg Config, metrics *Metrics) (*Server, error) {
 		httpServer.TLSConfig = httpTLSConfig
 	}
 
-	handler := cfg.SignalHandler
-	if handler == nil {
-		handler = signals.NewHandler(log)
-	}
-
-	return &Server{
+	s := &Server{
 		cfg:                cfg,
+		metrics:            metrics,
 		httpListener:       httpListener,
 		grpcListener:       grpcListener,
+		httpRawListener:    httpRawListener,
+		grpcRawListener:    grpcRawListener,
+		upgradeReady:       upgradeReadyFile,
+		serving:            serving,
 		grpcOnHTTPListener: grpcOnHTTPListener,
-		handler:            handler,
+		handler:            cfg.SignalHandler,
 		grpchttpmux:        grpchttpmux,
+		tlsReloaders:       tlsReloaders,
+		logSampler:         logSampler,
 
 		HTTP:             router,
 		HTTPServer:       httpServer,
 		GRPC:             grpcServer,
 		GRPCOnHTTPServer: grpcOnHttpServer,
+		Health:           health,
 		Log:              log,
+		LogLevel:         logLevel,
 		Registerer:       cfg.registererOrDefault(),
 		Gatherer:         gatherer,
-	}, nil
+	}
+
+	if s.handler == nil {
+		handler := signals.NewHandler(log)
+		if cfg.GracefulUpgrade {
+			s.registerUpgradeSignal(handler)
+		}
+		s.handler = handler
+	}
+	return s, nil
+}
+
+// defaultLogger makes the logrus logger used if Config.Log isn't set. Unless
+// LogFormat has been set, it logs in logrus's text format, as it always has,
+// so the output of existing servers doesn't change.
+func defaultLogger(format logging.Format, level *logging.DynamicLevel) logging.Interface {
+	if format.IsSet() {
+		return logging.NewLogrusFormatDynamic(level, format)
+	}
+	return logging.NewLogrusDynamic(level)
 }
 
 // RegisterInstrumentation on the given router.
@@ -471,6 +667,13 @@ func RegisterInstrumentationWithGatherer(router *mux.Router, gatherer prometheus
 func (s *Server) Run() error {
 	errChan := make(chan error, 1)
 
+	for _, r := range s.tlsReloaders {
+		r.start()
+	}
+
+	// If we were started by Upgrade, tell the old process once we're serving.
+	go s.notifyUpgradeReady()
+
 	// Wait for a signal
 	go func() {
 		s.handler.Loop()
@@ -485,7 +688,8 @@ func (s *Server) Run() error {
 		if s.HTTPServer.TLSConfig == nil {
 			err = s.HTTPServer.Serve(s.httpListener)
 		} else {
-			err = s.HTTPServer.ServeTLS(s.httpListener, s.cfg.HTTPTLSConfig.TLSCertPath, s.cfg.HTTPTLSConfig.TLSKeyPath)
+			// The certificate is served by TLSConfig.GetCertificate, so it can be reloaded.
+			err = s.HTTPServer.ServeTLS(s.httpListener, "", "")
 		}
 		if err == http.ErrServerClosed {
 			err = nil
@@ -499,7 +703,15 @@ func (s *Server) Run() error {
 
 	// Setup gRPC server
 	// for HTTP over gRPC, ensure we don't double-count the middleware
-	httpgrpc.RegisterHTTPServer(s.GRPC, httpgrpc_server.NewServer(s.HTTP))
+	httpgrpcOptions := []httpgrpc_server.ServerOption{
+		httpgrpc_server.WithCompressionMetrics(s.metrics.HTTPGRPCUncompressedBytes, s.metrics.HTTPGRPCCompressedBytes),
+	}
+	if s.cfg.HTTPGRPCTrustedProxyTLS {
+		httpgrpcOptions = append(httpgrpcOptions, httpgrpc_server.WithTrustedProxyTLS())
+	}
+	httpgrpcServer := httpgrpc_server.NewServer(s.HTTP, httpgrpcOptions...)
+	httpgrpc.RegisterHTTPServer(s.GRPC, httpgrpcServer)
+	httpgrpc.RegisterHTTPStreamServer(s.GRPC, httpgrpcServer)
 
 	go func() {
 		err := s.GRPC.Serve(s.grpcListener)
@@ -551,10 +763,75 @@ func (s *Server) Stop() {
 }
 
 // Shutdown the server, gracefully.  Should be defered after New().
+//
+// Shutdown happens in phases: the server is marked as not ready, then keeps
+// serving for ServerPreStopDelay so load balancers can notice, then the HTTP,
+// gRPC and gRPC-over-HTTP servers are drained concurrently. Any that have not
+// drained within ServerGracefulShutdownTimeout are forcefully stopped.
 func (s *Server) Shutdown() {
+	s.Health.SetShuttingDown()
+
+	if s.cfg.ServerPreStopDelay > 0 {
+		s.Log.Infof("server marked as not ready, waiting %s before shutting down", s.cfg.ServerPreStopDelay)
+		time.Sleep(s.cfg.ServerPreStopDelay)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ServerGracefulShutdownTimeout)
-	defer cancel() // releases resources if httpServer.Shutdown completes before timeout elapses
+	defer cancel() // releases resources if draining completes before timeout elapses
+
+	// Capture what is still running at the deadline, before forcing it to stop.
+	forceCtx, force := context.WithCancel(context.Background())
+	defer force()
+	inflight := make(chan []string, 1)
+	go func() {
+		<-ctx.Done()
+		inflight <- inflightRequests(s.metrics.InflightRequests)
+		force()
+	}()
+
+	stops := map[string]func() bool{
+		"http": func() bool {
+			// Other errors, like those closing a listener which cmux shares
+			// with the gRPC server, don't mean requests were cut short.
+			if err := s.HTTPServer.Shutdown(forceCtx); err != nil && err == forceCtx.Err() {
+				s.HTTPServer.Close()
+				return false
+			}
+			return true
+		},
+		"grpc": func() bool {
+			return gracefulStopGRPC(forceCtx, s.GRPC)
+		},
+	}
+	if s.grpchttpmux != nil {
+		stops["grpc-over-http"] = func() bool {
+			return gracefulStopGRPC(forceCtx, s.GRPCOnHTTPServer)
+		}
+	}
 
-	s.HTTPServer.Shutdown(ctx)
-	s.GRPC.GracefulStop()
+	if forced := drain(stops); len(forced) > 0 {
+		// Servers are only forced to stop once the deadline has passed, by
+		// when the in-flight requests have been captured.
+		var stillInflight []string
+		select {
+		case stillInflight = <-inflight:
+		default:
+		}
+		s.Log.WithField("servers", strings.Join(forced, ",")).
+			WithField("inflight", strings.Join(stillInflight, ", ")).
+			Warnf("graceful shutdown timed out after %s, forced stop", s.cfg.ServerGracefulShutdownTimeout)
+	}
+
+	// Stopping the servers closed the listeners, and with them the one cmux
+	// shares between them.
+	if s.grpchttpmux != nil {
+		s.grpchttpmux.Close()
+	}
+
+	for _, r := range s.tlsReloaders {
+		r.stop()
+	}
+	if s.logSampler != nil {
+		s.logSampler.Stop()
+	}
 }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+)
+
+// gracefulStopGRPC stops s gracefully, or forcefully once ctx is done.
+// It returns false if the server had to be forcefully stopped.
+func gracefulStopGRPC(ctx context.Context, s *grpc.Server) bool {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		// Stop also unblocks the GracefulStop above.
+		s.Stop()
+		<-done
+		return false
+	}
+}
+
+// drain runs each of the given stop functions concurrently, and returns the names
+// of those that did not finish gracefully.
+func drain(stops map[string]func() bool) []string {
+	var (
+		wg     sync.WaitGroup
+		mtx    sync.Mutex
+		forced []string
+	)
+	for name, stop := range stops {
+		wg.Add(1)
+		go func(name string, stop func() bool) {
+			defer wg.Done()
+			if !stop() {
+				mtx.Lock()
+				forced = append(forced, name)
+				mtx.Unlock()
+			}
+		}(name, stop)
+	}
+	wg.Wait()
+	sort.Strings(forced)
+	return forced
+}
+
+// inflightRequests describes the non-zero in-flight request counts in g, as
+// "method route=count" strings.
+func inflightRequests(g *prometheus.GaugeVec) []string {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		g.Collect(ch)
+		close(ch)
+	}()
+
+	var result []string
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil || pb.GetGauge().GetValue() == 0 {
+			continue
+		}
+		var labels []string
+		for _, l := range pb.GetLabel() {
+			labels = append(labels, l.GetValue())
+		}
+		result = append(result, strings.Join(labels, " ")+"="+strconv.FormatFloat(pb.GetGauge().GetValue(), 'f', -1, 64))
+	}
+	sort.Strings(result)
+	return result
+}
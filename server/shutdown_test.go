@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	protobuf "github.com/golang/protobuf/ptypes/empty"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestShutdownForcesStopAfterDeadline(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.HTTPListenPort = 9200
+	cfg.GRPCListenAddress = "localhost"
+	cfg.GRPCListenPort = 9201
+	cfg.ServerGracefulShutdownTimeout = 200 * time.Millisecond
+	cfg.Registerer = prometheus.NewRegistry()
+	server, err := New(cfg)
+	require.NoError(t, err)
+
+	RegisterFakeServerServer(server.GRPC, FakeServer{})
+	go server.Run()
+
+	conn, err := grpc.Dial("localhost:9201", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewFakeServerClient(conn)
+
+	// Sleep won't return for 10s unless the server goes away.
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := client.Sleep(context.Background(), &protobuf.Empty{})
+		errChan <- err
+	}()
+	time.Sleep(100 * time.Millisecond) // allow the call to reach the handler
+
+	require.Equal(t, []string{"gRPC /server.FakeServer/Sleep=1"}, inflightRequests(server.metrics.InflightRequests))
+
+	start := time.Now()
+	server.Shutdown()
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	require.True(t, server.Health.ShuttingDown())
+	require.Error(t, <-errChan)
+}
+
+func TestDrain(t *testing.T) {
+	forced := drain(map[string]func() bool{
+		"a": func() bool { return true },
+		"b": func() bool { return false },
+		"c": func() bool { return false },
+	})
+	require.Equal(t, []string{"b", "c"}, forced)
+}
+
+func TestShutdownWithGRPCOverHTTP(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.HTTPListenAddress = "localhost"
+	cfg.HTTPListenPort = 9204
+	cfg.GRPCListenAddress = "localhost"
+	cfg.GRPCListenPort = 9205
+	cfg.RouteHTTPToGRPC = true
+	cfg.ServerGracefulShutdownTimeout = 5 * time.Second
+	cfg.Registerer = prometheus.NewRegistry()
+	server, err := New(cfg)
+	require.NoError(t, err)
+
+	RegisterFakeServerServer(server.GRPCOnHTTPServer, FakeServer{})
+	go server.Run()
+
+	conn, err := grpc.Dial("localhost:9204", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = NewFakeServerClient(conn).Succeed(context.Background(), &protobuf.Empty{})
+	require.NoError(t, err)
+
+	// The HTTP and gRPC servers share the listener, so one of them fails to
+	// close it, which must not be taken as a timeout.
+	start := time.Now()
+	server.Shutdown()
+	require.Less(t, int64(time.Since(start)), int64(cfg.ServerGracefulShutdownTimeout))
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/common/logging"
+)
+
+// certReloader holds the certificate and client CA pool for one listener, and
+// periodically re-reads them from disk so that rotated certificates are picked
+// up without restarting the server.
+type certReloader struct {
+	protocol string
+	cfg      TLSConfig
+	base     *tls.Config
+	interval time.Duration
+	log      logging.Interface
+	reloads  *prometheus.CounterVec
+	expiry   prometheus.Gauge
+
+	cert      atomic.Value // *tls.Certificate
+	clientCAs atomic.Value // *x509.CertPool
+
+	// Contents of the files at the last successful load; only accessed from reload().
+	mtx      sync.Mutex
+	contents [][]byte
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// newCertReloader loads the files referenced by cfg, failing if they are invalid.
+// base supplies all the other TLS settings; nextProtos must be set to what the
+// serving listener advertises, as per-connection configs do not inherit them.
+func newCertReloader(protocol string, cfg TLSConfig, base *tls.Config, nextProtos []string, interval time.Duration, log logging.Interface, metrics *Metrics) (*certReloader, error) {
+	base = base.Clone()
+	base.NextProtos = nextProtos
+	base.Certificates = nil
+	base.GetCertificate = nil
+
+	r := &certReloader{
+		protocol: protocol,
+		cfg:      cfg,
+		base:     base,
+		interval: interval,
+		log:      log,
+		reloads:  metrics.TLSCertificateReloads,
+		expiry:   metrics.TLSCertificateExpiry.WithLabelValues(protocol),
+		quit:     make(chan struct{}),
+	}
+	r.clientCAs.Store(base.ClientCAs)
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a tls.Config which always serves the most recently loaded
+// certificate and client CAs.
+func (r *certReloader) TLSConfig() *tls.Config {
+	cfg := r.base.Clone()
+	cfg.GetCertificate = r.getCertificate
+	cfg.GetConfigForClient = r.getConfigForClient
+	return cfg
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load().(*tls.Certificate), nil
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cfg := r.base.Clone()
+	cfg.GetCertificate = r.getCertificate
+	cfg.ClientCAs = r.clientCAs.Load().(*x509.CertPool)
+	return cfg, nil
+}
+
+// start watches the files for changes until stop is called.
+func (r *certReloader) start() {
+	if r.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				changed, err := r.reload()
+				if err != nil {
+					r.log.WithField("protocol", r.protocol).Warnf("error reloading TLS certificate: %v", err)
+				} else if changed {
+					r.log.WithField("protocol", r.protocol).Infof("reloaded TLS certificate")
+				}
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (r *certReloader) stop() {
+	r.stopOnce.Do(func() { close(r.quit) })
+}
+
+// reload re-reads the certificate, key and client CA files, and swaps them in
+// if they have changed and are valid. The previous certificate is kept on error.
+func (r *certReloader) reload() (bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	paths := []string{r.cfg.TLSCertPath, r.cfg.TLSKeyPath}
+	if r.cfg.ClientCAs != "" {
+		paths = append(paths, r.cfg.ClientCAs)
+	}
+	contents := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			r.reloads.WithLabelValues(r.protocol, "failure").Inc()
+			return false, err
+		}
+		contents = append(contents, b)
+	}
+	if r.unchanged(contents) {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		r.reloads.WithLabelValues(r.protocol, "failure").Inc()
+		return false, fmt.Errorf("error loading certificate %s and key %s: %v", r.cfg.TLSCertPath, r.cfg.TLSKeyPath, err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		r.reloads.WithLabelValues(r.protocol, "failure").Inc()
+		return false, fmt.Errorf("error parsing certificate %s: %v", r.cfg.TLSCertPath, err)
+	}
+	cert.Leaf = leaf
+
+	clientCAs := r.clientCAs.Load().(*x509.CertPool)
+	if len(contents) > 2 {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[2]) {
+			r.reloads.WithLabelValues(r.protocol, "failure").Inc()
+			return false, fmt.Errorf("no valid certificates found in client CA file %s", r.cfg.ClientCAs)
+		}
+	}
+
+	r.cert.Store(&cert)
+	r.clientCAs.Store(clientCAs)
+	r.contents = contents
+	r.reloads.WithLabelValues(r.protocol, "success").Inc()
+	r.expiry.Set(float64(leaf.NotAfter.Unix()))
+	return true, nil
+}
+
+func (r *certReloader) unchanged(contents [][]byte) bool {
+	if len(contents) != len(r.contents) {
+		return false
+	}
+	for i := range contents {
+		if !bytes.Equal(contents[i], r.contents[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weaveworks/common/logging"
+)
+
+func writeSelfSignedCert(t *testing.T, dir, cn string, notAfter time.Time) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	firstExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certPath, keyPath := writeSelfSignedCert(t, dir, "first", firstExpiry)
+
+	metrics := NewServerMetrics(Config{MetricsNamespace: "testing_tls_reload"})
+	r, err := newCertReloader("http", TLSConfig{TLSCertPath: certPath, TLSKeyPath: keyPath}, &tls.Config{}, []string{"h2", "http/1.1"}, time.Minute, logging.Noop(), metrics)
+	require.NoError(t, err)
+
+	cfg := r.TLSConfig()
+	require.Equal(t, []string{"h2", "http/1.1"}, cfg.NextProtos)
+	cert, err := cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "first", cert.Leaf.Subject.CommonName)
+	require.Equal(t, float64(firstExpiry.Unix()), testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("http")))
+
+	// Nothing changed on disk.
+	changed, err := r.reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Rotate the certificate.
+	secondExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	writeSelfSignedCert(t, dir, "second", secondExpiry)
+	changed, err = r.reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "second", cert.Leaf.Subject.CommonName)
+	require.Equal(t, float64(secondExpiry.Unix()), testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("http")))
+
+	// A broken certificate is rejected, and the previous one kept.
+	require.NoError(t, os.WriteFile(certPath, []byte("not a certificate"), 0o600))
+	_, err = r.reload()
+	require.Error(t, err)
+
+	cert, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "second", cert.Leaf.Subject.CommonName)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.TLSCertificateReloads.WithLabelValues("http", "success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.TLSCertificateReloads.WithLabelValues("http", "failure")))
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upgradeEnv is set by a Server which is handing its listeners over to a new
+// process. It lists the names of the inherited file descriptors, in order,
+// starting from listenFDsStart.
+const upgradeEnv = "WEAVEWORKS_SERVER_UPGRADE_FDS"
+
+const (
+	upgradeHTTP  = "http"
+	upgradeGRPC  = "grpc"
+	upgradeReady = "ready"
+)
+
+// inheritedListeners returns the listeners, and the pipe to report readiness
+// on, handed over by the parent process if this process was started by Upgrade.
+func inheritedListeners() (map[string]net.Listener, *os.File, error) {
+	names := os.Getenv(upgradeEnv)
+	if names == "" {
+		return nil, nil, nil
+	}
+	// Don't let any process we start think it has inherited them too.
+	os.Unsetenv(upgradeEnv)
+
+	listeners := map[string]net.Listener{}
+	var ready *os.File
+	for i, name := range strings.Split(names, ":") {
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		if name == upgradeReady {
+			ready = f
+			continue
+		}
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error using inherited %s listener: %v", name, err)
+		}
+		listeners[name] = l
+	}
+	return listeners, ready, nil
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+func listenerFile(l net.Listener) (*os.File, error) {
+	f, ok := l.(filer)
+	if !ok {
+		return nil, fmt.Errorf("cannot hand over %s listener", l.Addr().Network())
+	}
+	return f.File()
+}
+
+// upgradeEnviron returns the environment for the new process, without any
+// socket activation variables which only applied to this one.
+func upgradeEnviron(environ []string, fds []string) []string {
+	result := make([]string, 0, len(environ)+1)
+	for _, kv := range environ {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", upgradeEnv:
+			continue
+		}
+		result = append(result, kv)
+	}
+	return append(result, upgradeEnv+"="+strings.Join(fds, ":"))
+}
+
+// waitForReady waits for the new process to write to the ready pipe. If the
+// process exits first, the pipe is closed with nothing written.
+func waitForReady(r io.Reader, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, len(upgradeReady))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			result <- errors.New("new process exited before becoming ready")
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("new process did not become ready within %s", timeout)
+	}
+}
+
+// Upgrade starts a new copy of the running binary, with the same arguments,
+// and hands it this server's HTTP and gRPC listeners. Once the new process is
+// serving, Run returns so the caller can Shutdown this server and drain its
+// connections. Until then this server keeps serving; on error it carries on.
+func (s *Server) Upgrade() error {
+	s.upgradeMtx.Lock()
+	defer s.upgradeMtx.Unlock()
+
+	httpFile, err := listenerFile(s.httpRawListener)
+	if err != nil {
+		return err
+	}
+	defer httpFile.Close()
+	grpcFile, err := listenerFile(s.grpcRawListener)
+	if err != nil {
+		return err
+	}
+	defer grpcFile.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = upgradeEnviron(os.Environ(), []string{upgradeHTTP, upgradeGRPC, upgradeReady})
+	cmd.ExtraFiles = []*os.File{httpFile, grpcFile, w}
+	err = cmd.Start()
+	w.Close() // Only the new process should hold the write end.
+	if err != nil {
+		return fmt.Errorf("error starting new process: %v", err)
+	}
+	pid := cmd.Process.Pid
+	s.Log.Infof("started new process %d, waiting for it to become ready", pid)
+
+	if err := waitForReady(r, s.cfg.GracefulUpgradeTimeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	cmd.Process.Release()
+
+	// The new process serves on unix sockets now, so they must stay when we
+	// close our listeners.
+	for _, l := range []net.Listener{s.httpRawListener, s.grpcRawListener} {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	s.Log.Infof("new process %d is ready, shutting down", pid)
+	s.Stop()
+	return nil
+}
+
+// servingListener records when a server starts accepting connections on it.
+type servingListener struct {
+	net.Listener
+	once    sync.Once
+	serving chan struct{}
+}
+
+func newServingListener(l net.Listener) *servingListener {
+	return &servingListener{Listener: l, serving: make(chan struct{})}
+}
+
+func (l *servingListener) Accept() (net.Conn, error) {
+	l.once.Do(func() { close(l.serving) })
+	return l.Listener.Accept()
+}
+
+// notifyUpgradeReady tells the process which started this one via Upgrade that
+// it is now serving, once a server is accepting connections on each listener.
+func (s *Server) notifyUpgradeReady() {
+	if s.upgradeReady == nil {
+		return
+	}
+	for _, l := range s.serving {
+		<-l.serving
+	}
+	if _, err := s.upgradeReady.Write([]byte(upgradeReady)); err != nil {
+		s.Log.Warnf("error notifying parent process of readiness: %v", err)
+	}
+	s.upgradeReady.Close()
+	s.upgradeReady = nil
+}
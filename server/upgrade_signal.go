@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import (
+	"syscall"
+
+	"github.com/weaveworks/common/signals"
+)
+
+// registerUpgradeSignal makes SIGUSR2 trigger an Upgrade.
+func (s *Server) registerUpgradeSignal(h *signals.Handler) {
+	h.On(syscall.SIGUSR2, func() {
+		go func() {
+			if err := s.Upgrade(); err != nil {
+				s.Log.Errorf("error upgrading server: %v", err)
+			}
+		}()
+	})
+}
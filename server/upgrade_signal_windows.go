@@ -0,0 +1,8 @@
+package server
+
+import (
+	"github.com/weaveworks/common/signals"
+)
+
+// registerUpgradeSignal does nothing, as there is no upgrade signal on Windows.
+func (s *Server) registerUpgradeSignal(_ *signals.Handler) {}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weaveworks/common/logging"
+)
+
+// TestMain runs the server of TestUpgrade when Upgrade starts the test binary
+// again, rather than the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv(upgradeEnv) == "" {
+		os.Exit(m.Run())
+	}
+
+	server, err := New(upgradeTestConfig(""))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	handleUpgradeTest(server)
+	err = server.Run()
+	server.Shutdown()
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func upgradeTestConfig(dir string) Config {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ExitOnError))
+	cfg.HTTPListenNetwork = NetworkUnix
+	cfg.HTTPListenAddress = filepath.Join(dir, "http.sock")
+	cfg.GRPCListenNetwork = NetworkUnix
+	cfg.GRPCListenAddress = filepath.Join(dir, "grpc.sock")
+	cfg.GracefulUpgrade = true
+	cfg.GracefulUpgradeTimeout = 30 * time.Second
+	cfg.Registerer = prometheus.NewRegistry()
+	cfg.Log = logging.Noop()
+	return cfg
+}
+
+// handleUpgradeTest serves the ID of the process, and lets the test stop it.
+func handleUpgradeTest(server *Server) {
+	server.HTTP.HandleFunc("/pid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, os.Getpid())
+	})
+	server.HTTP.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+		server.Stop()
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("listeners can't be handed over on Windows")
+	}
+
+	dir := t.TempDir()
+	cfg := upgradeTestConfig(dir)
+	server, err := New(cfg)
+	require.NoError(t, err)
+	handleUpgradeTest(server)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run()
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, NetworkUnix, cfg.HTTPListenAddress)
+		},
+		DisableKeepAlives: true,
+	}}
+	pid := func() int {
+		res, err := client.Get("http://server/pid")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(string(body))
+		require.NoError(t, err)
+		return pid
+	}
+	require.Equal(t, os.Getpid(), pid())
+
+	// Once the new process is serving, Run returns, and shutting this server
+	// down leaves the sockets for the new process.
+	require.NoError(t, server.Upgrade())
+	require.NoError(t, <-runErr)
+	server.Shutdown()
+	for _, path := range []string{cfg.HTTPListenAddress, cfg.GRPCListenAddress} {
+		_, err := os.Stat(path)
+		require.NoError(t, err)
+	}
+
+	require.NotEqual(t, os.Getpid(), pid())
+	res, err := client.Get("http://server/stop")
+	require.NoError(t, err)
+	res.Body.Close()
+}
+
+func TestUpgradeEnviron(t *testing.T) {
+	env := upgradeEnviron([]string{
+		"HOME=/root",
+		"LISTEN_PID=1",
+		"LISTEN_FDS=2",
+		"LISTEN_FDNAMES=http:grpc",
+		upgradeEnv + "=http:grpc:ready",
+	}, []string{upgradeHTTP, upgradeGRPC, upgradeReady})
+	require.Equal(t, []string{"HOME=/root", upgradeEnv + "=http:grpc:ready"}, env)
+}
+
+func TestWaitForReady(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		defer r.Close()
+
+		s := &Server{upgradeReady: w, Log: logging.Noop()}
+		go s.notifyUpgradeReady()
+		require.NoError(t, waitForReady(r, 5*time.Second))
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		defer r.Close()
+
+		require.NoError(t, w.Close())
+		require.EqualError(t, waitForReady(r, 5*time.Second), "new process exited before becoming ready")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		defer r.Close()
+		defer w.Close()
+
+		require.EqualError(t, waitForReady(r, 10*time.Millisecond), "new process did not become ready within 10ms")
+	})
+}
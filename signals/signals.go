@@ -17,10 +17,12 @@ type SignalReceiver interface {
 
 // Handler handles signals, can be interrupted.
 // On SIGINT or SIGTERM it will exit, on SIGQUIT it
-// will dump goroutine stacks to the Logger.
+// will dump goroutine stacks to the Logger. Other
+// signals can be handled by registering them with On.
 type Handler struct {
 	log       logging.Interface
 	receivers []SignalReceiver
+	handlers  map[os.Signal]func()
 	quit      chan struct{}
 }
 
@@ -29,10 +31,18 @@ func NewHandler(log logging.Interface, receivers ...SignalReceiver) *Handler {
 	return &Handler{
 		log:       log,
 		receivers: receivers,
+		handlers:  map[os.Signal]func(){},
 		quit:      make(chan struct{}),
 	}
 }
 
+// On calls f whenever sig is received. f is called from the Loop goroutine,
+// so should not block. SIGINT, SIGTERM and SIGQUIT cannot be overridden.
+// Must be called before Loop.
+func (h *Handler) On(sig os.Signal, f func()) {
+	h.handlers[sig] = f
+}
+
 // Stop the handler
 func (h *Handler) Stop() {
 	close(h.quit)
@@ -42,6 +52,9 @@ func (h *Handler) Stop() {
 func (h *Handler) Loop() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	for sig := range h.handlers {
+		signal.Notify(sigs, sig)
+	}
 	defer signal.Stop(sigs)
 	buf := make([]byte, 1<<20)
 	for {
@@ -60,6 +73,11 @@ func (h *Handler) Loop() {
 			case syscall.SIGQUIT:
 				stacklen := runtime.Stack(buf, true)
 				h.log.Infof("=== received SIGQUIT ===\n*** goroutine dump...\n%s\n*** end", buf[:stacklen])
+			default:
+				if f, ok := h.handlers[sig]; ok {
+					h.log.Infof("=== received %s ===", sig)
+					f()
+				}
 			}
 		}
 	}
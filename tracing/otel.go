@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// otelShutdownTimeout is how long closing the tracer waits for spans to be
+// exported.
+const otelShutdownTimeout = 5 * time.Second
+
+// installOTel registers OpenTelemetry, exporting spans with exporter, as the
+// OpenTracing implementation, through the OpenTracing bridge.
+func installOTel(serviceName string, propagators Propagators, exporter sdktrace.SpanExporter, options ...sdktrace.TracerProviderOption) (io.Closer, error) {
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name.
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create opentelemetry resource")
+	}
+
+	// put the exporter and resource first so provided options can override them
+	opts := append([]sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)}, options...)
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	bridge, wrapper := otbridge.NewTracerPair(provider.Tracer("github.com/weaveworks/common/tracing"))
+	otel.SetTracerProvider(wrapper)
+	otel.SetTextMapPropagator(propagators.TextMapPropagator())
+	opentracing.SetGlobalTracer(bridge)
+
+	return closerFunc(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}), nil
+}
+
+// NewOTelFromEnv is a convenience function to set up OpenTelemetry tracing,
+// exporting spans with OTLP, configured by the standard OTEL_* environment
+// variables. It is installed as the OpenTracing global tracer, so middleware
+// using OpenTracing works as it does with Jaeger.
+//
+// Tracing will be enabled if one of the following environment variables is used to configure the OTLP endpoint:
+// - OTEL_EXPORTER_OTLP_ENDPOINT
+// - OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+//
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL choose
+// between "grpc" and "http/protobuf", the default. OTEL_PROPAGATORS lists the
+// formats trace context is propagated in, by default "tracecontext,baggage";
+// see Propagators. OTEL_TRACES_SAMPLER and the other exporter and resource
+// variables apply as usual.
+func NewOTelFromEnv(serviceName string, options ...sdktrace.TracerProviderOption) (io.Closer, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil, ErrBlankTraceConfiguration
+	}
+
+	propagators, ok, err := propagatorsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		propagators = Propagators{PropagatorTraceContext, PropagatorBaggage}
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	var exporter sdktrace.SpanExporter
+	switch protocol {
+	case "grpc":
+		exporter, err = otlptracegrpc.New(context.Background())
+	case "", "http/protobuf":
+		exporter, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, errors.Errorf("unsupported OTLP protocol %q", protocol)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create OTLP exporter")
+	}
+
+	return installOTel(serviceName, propagators, exporter, options...)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
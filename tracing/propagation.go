@@ -0,0 +1,357 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the propagators, as used in OTEL_PROPAGATORS.
+const (
+	PropagatorJaeger       = "jaeger"
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+
+	propagatorNone = "none"
+)
+
+const (
+	traceparentHeader   = "traceparent"
+	baggageHeader       = "baggage"
+	jaegerTraceHeader   = "uber-trace-id"
+	jaegerBaggagePrefix = "uberctx-"
+)
+
+// Propagators lists the formats in which trace context and baggage are sent
+// to, and accepted from, other services over HTTP and gRPC:
+// - jaeger: the uber-trace-id and uberctx-* headers
+// - tracecontext: the W3C traceparent and tracestate headers
+// - baggage: the W3C baggage header
+//
+// All of them are sent. If a request has trace context in more than one
+// format, the last in the list is used.
+type Propagators []string
+
+// ParsePropagators parses a comma-separated list of propagators, as in
+// OTEL_PROPAGATORS. "none" disables propagation.
+func ParsePropagators(s string) (Propagators, error) {
+	p := Propagators{}
+	for _, name := range strings.Split(s, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "", propagatorNone:
+		case PropagatorJaeger, PropagatorTraceContext, PropagatorBaggage:
+			p = append(p, name)
+		default:
+			return nil, errors.Errorf("unsupported propagator %q", name)
+		}
+	}
+	return p, nil
+}
+
+// propagatorsFromEnv returns the propagators in OTEL_PROPAGATORS, and
+// whether it is set.
+func propagatorsFromEnv() (Propagators, bool, error) {
+	s := os.Getenv("OTEL_PROPAGATORS")
+	if s == "" {
+		return nil, false, nil
+	}
+	p, err := ParsePropagators(s)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "invalid OTEL_PROPAGATORS")
+	}
+	return p, true, nil
+}
+
+// TextMapPropagator returns an OpenTelemetry propagator for these formats.
+// Set it with otel.SetTextMapPropagator to use it through the OpenTracing
+// bridge.
+func (p Propagators) TextMapPropagator() propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range p {
+		switch name {
+		case PropagatorJaeger:
+			propagators = append(propagators, otelJaegerPropagator{})
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// JaegerOptions configures a Jaeger tracer to inject and extract these
+// formats, in HTTP headers and in gRPC metadata. The Jaeger client has no
+// equivalent of tracestate, so it is not passed on.
+func (p Propagators) JaegerOptions() []jaegercfg.Option {
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	metrics := *jaeger.NewNullMetrics()
+	var httpHeaders, textMap jaegerPropagators
+	for _, name := range p {
+		switch name {
+		case PropagatorJaeger:
+			httpHeaders = append(httpHeaders, jaeger.NewHTTPHeaderPropagator(headers, metrics))
+			textMap = append(textMap, jaeger.NewTextMapPropagator(headers, metrics))
+		case PropagatorTraceContext:
+			httpHeaders = append(httpHeaders, jaegerTraceContext{})
+			textMap = append(textMap, jaegerTraceContext{})
+		case PropagatorBaggage:
+			httpHeaders = append(httpHeaders, jaegerBaggage{})
+			textMap = append(textMap, jaegerBaggage{})
+		}
+	}
+	return []jaegercfg.Option{
+		jaegercfg.Injector(opentracing.HTTPHeaders, httpHeaders),
+		jaegercfg.Extractor(opentracing.HTTPHeaders, httpHeaders),
+		jaegercfg.Injector(opentracing.TextMap, textMap),
+		jaegercfg.Extractor(opentracing.TextMap, textMap),
+	}
+}
+
+type jaegerPropagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+// jaegerPropagators injects all its formats, and extracts the span context
+// from the last one that has it, with the baggage from all of them.
+type jaegerPropagators []jaegerPropagator
+
+func (ps jaegerPropagators) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	for _, p := range ps {
+		if err := p.Inject(sc, carrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ps jaegerPropagators) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	var result jaeger.SpanContext
+	found := false
+	baggage := map[string]string{}
+	for _, p := range ps {
+		sc, err := p.Extract(carrier)
+		if err == opentracing.ErrSpanContextNotFound {
+			continue
+		} else if err != nil {
+			return jaeger.SpanContext{}, err
+		}
+		if sc.IsValid() {
+			result, found = sc, true
+		}
+		sc.ForeachBaggageItem(func(k, v string) bool {
+			baggage[k] = v
+			return true
+		})
+	}
+	if !found {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	for k, v := range baggage {
+		result = result.WithBaggageItem(k, v)
+	}
+	return result, nil
+}
+
+// jaegerTraceContext propagates Jaeger span contexts in the W3C traceparent
+// header.
+type jaegerTraceContext struct{}
+
+func (jaegerTraceContext) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	var flags trace.TraceFlags
+	if sc.IsSampled() {
+		flags = trace.FlagsSampled
+	}
+	w.Set(traceparentHeader, fmt.Sprintf("00-%016x%016x-%016x-%s", sc.TraceID().High, sc.TraceID().Low, uint64(sc.SpanID()), flags))
+	return nil
+}
+
+func (jaegerTraceContext) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	// Use the OpenTelemetry parser, rather than duplicate its validation.
+	ctx := propagation.TraceContext{}.Extract(context.Background(), textMapReaderCarrier{r})
+	otelSC := trace.SpanContextFromContext(ctx)
+	if !otelSC.IsValid() {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	traceID, spanID := otelSC.TraceID(), otelSC.SpanID()
+	jaegerTraceID, err := jaeger.TraceIDFromString(traceID.String())
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	jaegerSpanID, err := jaeger.SpanIDFromString(spanID.String())
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return jaeger.NewSpanContext(jaegerTraceID, jaegerSpanID, 0, otelSC.IsSampled(), nil), nil
+}
+
+// jaegerBaggage propagates the baggage of Jaeger span contexts in the W3C
+// baggage header.
+type jaegerBaggage struct{}
+
+func (jaegerBaggage) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	var members []baggage.Member
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		// Skip items W3C baggage can't carry, as the OpenTelemetry propagator does.
+		if m, err := baggage.NewMember(k, url.QueryEscape(v)); err == nil {
+			members = append(members, m)
+		}
+		return true
+	})
+	if len(members) == 0 {
+		return nil
+	}
+	b, err := baggage.New(members...)
+	if err != nil {
+		return nil
+	}
+	w.Set(baggageHeader, b.String())
+	return nil
+}
+
+func (jaegerBaggage) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	b := baggage.FromContext(propagation.Baggage{}.Extract(context.Background(), textMapReaderCarrier{r}))
+	if b.Len() == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	items := make(map[string]string, b.Len())
+	for _, m := range b.Members() {
+		items[m.Key()] = m.Value()
+	}
+	return jaeger.NewSpanContext(jaeger.TraceID{}, 0, 0, false, items), nil
+}
+
+// textMapReaderCarrier adapts an OpenTracing TextMapReader, whose keys may
+// be in any case, to read with OpenTelemetry propagators.
+type textMapReaderCarrier struct {
+	opentracing.TextMapReader
+}
+
+func (c textMapReaderCarrier) Get(key string) string {
+	var value string
+	_ = c.ForeachKey(func(k, v string) error {
+		if strings.EqualFold(k, key) {
+			value = v
+		}
+		return nil
+	})
+	return value
+}
+
+func (c textMapReaderCarrier) Set(key, value string) {}
+
+func (c textMapReaderCarrier) Keys() []string {
+	var keys []string
+	_ = c.ForeachKey(func(k, v string) error {
+		keys = append(keys, k)
+		return nil
+	})
+	return keys
+}
+
+// otelJaegerPropagator propagates OpenTelemetry span contexts and baggage in
+// the Jaeger uber-trace-id and uberctx-* headers.
+type otelJaegerPropagator struct{}
+
+func (otelJaegerPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		flags := 0
+		if sc.IsSampled() {
+			flags = 1
+		}
+		carrier.Set(jaegerTraceHeader, fmt.Sprintf("%s:%s:0:%x", sc.TraceID(), sc.SpanID(), flags))
+	}
+	for _, m := range baggage.FromContext(ctx).Members() {
+		carrier.Set(jaegerBaggagePrefix+m.Key(), url.QueryEscape(m.Value()))
+	}
+}
+
+func (otelJaegerPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if sc, ok := parseJaegerTraceHeader(carrier.Get(jaegerTraceHeader)); ok {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+
+	b := baggage.FromContext(ctx)
+	for _, k := range carrier.Keys() {
+		if !strings.HasPrefix(strings.ToLower(k), jaegerBaggagePrefix) {
+			continue
+		}
+		value, err := url.QueryUnescape(carrier.Get(k))
+		if err != nil {
+			continue
+		}
+		m, err := baggage.NewMember(strings.ToLower(k[len(jaegerBaggagePrefix):]), url.QueryEscape(value))
+		if err != nil {
+			continue
+		}
+		if b, err = b.SetMember(m); err != nil {
+			return ctx
+		}
+	}
+	if b.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, b)
+	}
+	return ctx
+}
+
+func (otelJaegerPropagator) Fields() []string {
+	return []string{jaegerTraceHeader}
+}
+
+// parseJaegerTraceHeader parses {trace-id}:{span-id}:{parent-span-id}:{flags},
+// where the IDs may be shorter than OpenTelemetry's, and the whole may be URL
+// encoded.
+func parseJaegerTraceHeader(s string) (trace.SpanContext, bool) {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		s = unescaped
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 || len(parts[0]) > 32 || len(parts[1]) > 16 {
+		return trace.SpanContext{}, false
+	}
+	traceID, err := trace.TraceIDFromHex(strings.Repeat("0", 32-len(parts[0])) + parts[0])
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(strings.Repeat("0", 16-len(parts[1])) + parts[1])
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	config := trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, Remote: true}
+	if flags&1 != 0 {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(config), true
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/require"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParsePropagators(t *testing.T) {
+	p, err := ParsePropagators("tracecontext, baggage,jaeger")
+	require.NoError(t, err)
+	require.Equal(t, Propagators{PropagatorTraceContext, PropagatorBaggage, PropagatorJaeger}, p)
+
+	p, err = ParsePropagators("none")
+	require.NoError(t, err)
+	require.Empty(t, p)
+
+	_, err = ParsePropagators("tracecontext,xray")
+	require.Error(t, err)
+}
+
+func newTestJaegerTracer(t *testing.T, p Propagators) opentracing.Tracer {
+	cfg := jaegercfg.Configuration{
+		ServiceName: "test",
+		Sampler:     &jaegercfg.SamplerConfig{Type: jaeger.SamplerTypeConst, Param: 1},
+	}
+	tracer, closer, err := cfg.NewTracer(append(p.JaegerOptions(), jaegercfg.Reporter(jaeger.NewNullReporter()))...)
+	require.NoError(t, err)
+	t.Cleanup(func() { closer.Close() })
+	return tracer
+}
+
+func TestJaegerPropagators(t *testing.T) {
+	tracer := newTestJaegerTracer(t, Propagators{PropagatorJaeger, PropagatorTraceContext, PropagatorBaggage})
+	sp := tracer.StartSpan("test")
+	sp.SetBaggageItem("org", "team/1")
+	sc := sp.Context().(jaeger.SpanContext)
+
+	header := http.Header{}
+	require.NoError(t, tracer.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+	require.Equal(t, sc.String(), header.Get(jaegerTraceHeader))
+	require.Equal(t, fmt.Sprintf("00-%016x%016x-%016x-01", sc.TraceID().High, sc.TraceID().Low, uint64(sc.SpanID())), header.Get(traceparentHeader))
+	require.Equal(t, "org=team%2F1", header.Get(baggageHeader))
+
+	// Services using other tracers only send W3C headers.
+	header.Del(jaegerTraceHeader)
+	header.Del("uberctx-org")
+	extracted, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+	require.Equal(t, sc.TraceID(), extracted.(jaeger.SpanContext).TraceID())
+	require.Equal(t, sc.SpanID(), extracted.(jaeger.SpanContext).SpanID())
+	require.True(t, extracted.(jaeger.SpanContext).IsSampled())
+	require.Equal(t, "team/1", tracer.StartSpan("child", opentracing.ChildOf(extracted)).BaggageItem("org"))
+
+	// gRPC metadata uses the TextMap format.
+	textMap := opentracing.TextMapCarrier{}
+	require.NoError(t, tracer.Inject(sp.Context(), opentracing.TextMap, textMap))
+	delete(textMap, jaegerTraceHeader)
+	extracted, err = tracer.Extract(opentracing.TextMap, textMap)
+	require.NoError(t, err)
+	require.Equal(t, sc.TraceID(), extracted.(jaeger.SpanContext).TraceID())
+
+	_, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{}))
+	require.Equal(t, opentracing.ErrSpanContextNotFound, err)
+}
+
+func TestJaegerPropagatorsNone(t *testing.T) {
+	tracer := newTestJaegerTracer(t, Propagators{})
+	header := http.Header{}
+	require.NoError(t, tracer.Inject(tracer.StartSpan("test").Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+	require.Empty(t, header)
+}
+
+func TestOTelJaegerPropagator(t *testing.T) {
+	propagator := Propagators{PropagatorJaeger}.TextMapPropagator()
+	tracer := newTestJaegerTracer(t, Propagators{PropagatorJaeger})
+
+	// From Jaeger to OpenTelemetry.
+	sp := tracer.StartSpan("test")
+	sp.SetBaggageItem("org", "team/1")
+	header := http.Header{}
+	require.NoError(t, tracer.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)))
+	ctx := propagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+	otelSC := trace.SpanContextFromContext(ctx)
+	require.True(t, otelSC.IsValid())
+	require.True(t, otelSC.IsRemote())
+	require.True(t, otelSC.IsSampled())
+	jaegerSC := sp.Context().(jaeger.SpanContext)
+	require.Equal(t, fmt.Sprintf("%016x%016x", jaegerSC.TraceID().High, jaegerSC.TraceID().Low), otelSC.TraceID().String())
+	require.Equal(t, fmt.Sprintf("%016x", uint64(jaegerSC.SpanID())), otelSC.SpanID().String())
+	require.Equal(t, "team/1", baggage.FromContext(ctx).Member("org").Value())
+
+	// And back again.
+	header = http.Header{}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	extracted, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+	require.Equal(t, jaegerSC.TraceID(), extracted.(jaeger.SpanContext).TraceID())
+	require.Equal(t, jaegerSC.SpanID(), extracted.(jaeger.SpanContext).SpanID())
+	require.Equal(t, "team/1", tracer.StartSpan("child", opentracing.ChildOf(extracted)).BaggageItem("org"))
+}
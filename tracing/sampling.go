@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	jaegerprom "github.com/uber/jaeger-lib/metrics/prometheus"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// RouteTag is set by middleware.Tracer and the gRPC server interceptors to
+// the HTTP route name or gRPC method of each request they serve. It marks the
+// span at whose end a SamplingReporter decides whether to keep the trace.
+const RouteTag = "route"
+
+const (
+	// How long spans wait for the request they are part of to complete,
+	// before their trace is decided on by the default rate alone.
+	maxPendingAge = 5 * time.Minute
+	// How many spans may wait, beyond which all waiting traces are decided.
+	maxPendingSpans = 100000
+	// How often to look for spans which have waited too long.
+	expiryInterval = time.Second
+)
+
+// SamplingConfig configures which traces a SamplingReporter keeps.
+type SamplingConfig struct {
+	Rate       float64       `yaml:"rate"`
+	Routes     RouteRates    `yaml:"routes"`
+	Errors     bool          `yaml:"errors"`
+	SlowerThan time.Duration `yaml:"slower_than"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *SamplingConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Float64Var(&cfg.Rate, "tracing.sampling-rate", 0.001, "Fraction of traces to keep, unless tracing.sampling-routes says otherwise.")
+	f.Var(&cfg.Routes, "tracing.sampling-routes", "Fraction of traces to keep by HTTP route name or gRPC method, as a comma-separated list of name=fraction.")
+	f.BoolVar(&cfg.Errors, "tracing.sample-errors", true, "Keep the traces of all requests which fail.")
+	f.DurationVar(&cfg.SlowerThan, "tracing.sample-slower-than", 0, "Keep the traces of all requests which take longer than this. 0 to disable.")
+}
+
+// keep returns true if the trace of a request should be kept.
+func (cfg SamplingConfig) keep(traceID jaeger.TraceID, route string, failed bool, duration time.Duration) bool {
+	if cfg.Errors && failed {
+		return true
+	}
+	if cfg.SlowerThan > 0 && duration >= cfg.SlowerThan {
+		return true
+	}
+	rate, ok := cfg.Routes[route]
+	if !ok {
+		rate = cfg.Rate
+	}
+	// As Jaeger's probabilistic sampler does, so the same traces are kept
+	// as by other services with the same rate.
+	const maxRandomNumber = ^(uint64(1) << 63)
+	return traceID.Low&maxRandomNumber < uint64(float64(maxRandomNumber)*rate)
+}
+
+// RouteRates are the fractions of traces to keep by HTTP route name, as
+// middleware.Tracer names them, or gRPC method, like /httpgrpc.HTTP/Handle.
+type RouteRates map[string]float64
+
+// String implements flag.Value.
+func (r RouteRates) String() string {
+	routes := make([]string, 0, len(r))
+	for route := range r {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for i, route := range routes {
+		routes[i] = fmt.Sprintf("%s=%v", route, r[route])
+	}
+	return strings.Join(routes, ",")
+}
+
+// Set implements flag.Value.
+func (r *RouteRates) Set(s string) error {
+	rates := RouteRates{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return errors.Errorf("expected route=fraction, got %q", item)
+		}
+		rate, err := strconv.ParseFloat(item[i+1:], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return errors.Errorf("invalid fraction for route %q: %q", item[:i], item[i+1:])
+		}
+		rates[item[:i]] = rate
+	}
+	*r = rates
+	return nil
+}
+
+// SamplingReporter is a Jaeger reporter which decides whether to keep each
+// trace once the request it traces completes, and passes the spans of those
+// it keeps to the next reporter. The decision is made at the end of the span
+// tagged with RouteTag, or the root span if there is none, so that it can
+// depend on whether the request failed, and how long it took.
+//
+// It can only drop spans it gets, so the tracer must sample every trace, and
+// services it calls see every trace as sampled. Each service decides for its
+// own spans.
+type SamplingReporter struct {
+	cfg  SamplingConfig
+	next jaeger.Reporter
+
+	mtx          sync.Mutex
+	pending      map[jaeger.TraceID]*pendingTrace
+	pendingSpans int
+	decided      map[jaeger.TraceID]samplingDecision
+	lastExpiry   time.Time
+}
+
+type pendingTrace struct {
+	spans   []*jaeger.Span
+	failed  bool
+	started time.Time
+}
+
+type samplingDecision struct {
+	keep bool
+	at   time.Time
+}
+
+// NewSamplingReporter makes a SamplingReporter, passing spans on to next.
+func NewSamplingReporter(cfg SamplingConfig, next jaeger.Reporter) *SamplingReporter {
+	return &SamplingReporter{
+		cfg:     cfg,
+		next:    next,
+		pending: map[jaeger.TraceID]*pendingTrace{},
+		decided: map[jaeger.TraceID]samplingDecision{},
+	}
+}
+
+// Report implements jaeger.Reporter.
+func (r *SamplingReporter) Report(span *jaeger.Span) {
+	ctx := span.SpanContext()
+	traceID := ctx.TraceID()
+	tags := span.Tags()
+	route, decide := tags[RouteTag].(string)
+	if !decide && ctx.ParentID() == 0 {
+		route, decide = span.OperationName(), true
+	}
+	failed := tags[string(ext.Error)] == true
+
+	now := mtime.Now()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.expire(now)
+
+	if d, ok := r.decided[traceID]; ok {
+		if d.keep {
+			r.next.Report(span)
+		}
+		return
+	}
+
+	p := r.pending[traceID]
+	if !decide {
+		if p == nil {
+			p = &pendingTrace{started: now}
+			r.pending[traceID] = p
+		}
+		p.spans = append(p.spans, span.Retain())
+		p.failed = p.failed || failed
+		r.pendingSpans++
+		if r.pendingSpans > maxPendingSpans {
+			for traceID, p := range r.pending {
+				r.decide(traceID, p, r.cfg.keep(traceID, "", p.failed, 0), now)
+			}
+		}
+		return
+	}
+
+	keep := r.cfg.keep(traceID, route, failed || (p != nil && p.failed), span.Duration())
+	if keep {
+		r.next.Report(span)
+	}
+	r.decide(traceID, p, keep, now)
+}
+
+// decide passes on or drops the pending spans of the trace, and remembers
+// the decision for any which end later.
+func (r *SamplingReporter) decide(traceID jaeger.TraceID, p *pendingTrace, keep bool, now time.Time) {
+	r.decided[traceID] = samplingDecision{keep: keep, at: now}
+	if p == nil {
+		return
+	}
+	for _, span := range p.spans {
+		if keep {
+			r.next.Report(span)
+		}
+		span.Release()
+	}
+	r.pendingSpans -= len(p.spans)
+	delete(r.pending, traceID)
+}
+
+// expire decides on traces whose spans have waited too long, and forgets
+// old decisions.
+func (r *SamplingReporter) expire(now time.Time) {
+	if now.Sub(r.lastExpiry) < expiryInterval {
+		return
+	}
+	r.lastExpiry = now
+	for traceID, p := range r.pending {
+		if now.Sub(p.started) >= maxPendingAge {
+			r.decide(traceID, p, r.cfg.keep(traceID, "", p.failed, 0), now)
+		}
+	}
+	for traceID, d := range r.decided {
+		if now.Sub(d.at) >= maxPendingAge {
+			delete(r.decided, traceID)
+		}
+	}
+}
+
+// Close implements jaeger.Reporter, deciding on the traces of spans still
+// waiting, and closing the next reporter.
+func (r *SamplingReporter) Close() {
+	now := mtime.Now()
+	r.mtx.Lock()
+	for traceID, p := range r.pending {
+		r.decide(traceID, p, r.cfg.keep(traceID, "", p.failed, 0), now)
+	}
+	r.mtx.Unlock()
+	r.next.Close()
+}
+
+// NewFromEnvWithSampling is like NewFromEnv, but traces are sampled as
+// configured by sampling, once the requests they trace complete, rather than
+// as configured by the JAEGER_SAMPLER_* environment variables.
+func NewFromEnvWithSampling(serviceName string, sampling SamplingConfig, options ...jaegercfg.Option) (io.Closer, error) {
+	cfg, options, err := configFromEnv(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tracer must use the same metrics factory as the reporter, or both
+	// would register the reporter's metrics.
+	metricsFactory := jaegerprom.New()
+	reporter, err := cfg.Reporter.NewReporter(serviceName, jaeger.NewMetrics(metricsFactory, nil), jaeger.NullLogger)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create jaeger reporter")
+	}
+	options = append([]jaegercfg.Option{
+		jaegercfg.Metrics(metricsFactory),
+		jaegercfg.Sampler(jaeger.NewConstSampler(true)),
+		jaegercfg.Reporter(NewSamplingReporter(sampling, reporter)),
+	}, options...)
+
+	closer, err := installJaeger(serviceName, cfg, options...)
+	if err != nil {
+		reporter.Close()
+		return nil, err
+	}
+	return closer, nil
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/require"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+func TestRouteRates(t *testing.T) {
+	var r RouteRates
+	require.NoError(t, r.Set("api_v1_push=1, /httpgrpc.HTTP/Handle=0.5"))
+	require.Equal(t, RouteRates{"api_v1_push": 1, "/httpgrpc.HTTP/Handle": 0.5}, r)
+	require.Equal(t, "/httpgrpc.HTTP/Handle=0.5,api_v1_push=1", r.String())
+
+	require.Error(t, r.Set("api_v1_push"))
+	require.Error(t, r.Set("api_v1_push=2"))
+}
+
+func TestSamplingReporter(t *testing.T) {
+	spans := jaeger.NewInMemoryReporter()
+	reporter := NewSamplingReporter(SamplingConfig{
+		Routes:     RouteRates{"keep": 1},
+		Errors:     true,
+		SlowerThan: time.Second,
+	}, spans)
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+
+	reported := func() []string {
+		var names []string
+		for _, sp := range spans.GetSpans() {
+			names = append(names, sp.(*jaeger.Span).OperationName())
+		}
+		spans.Reset()
+		return names
+	}
+	request := func(route string, child func(parent opentracing.Span)) opentracing.Span {
+		root := tracer.StartSpan(route)
+		// The server span may have a remote parent, and still decide.
+		server := tracer.StartSpan(route+" server", opentracing.ChildOf(root.Context()))
+		server.SetTag(RouteTag, route)
+		child(server)
+		return server
+	}
+
+	// Only the server span decides, and the rate for the route is 0.
+	sp := request("drop", func(parent opentracing.Span) {
+		tracer.StartSpan("child", opentracing.ChildOf(parent.Context())).Finish()
+	})
+	require.Empty(t, reported())
+	sp.Finish()
+	require.Empty(t, reported())
+
+	request("keep", func(parent opentracing.Span) {
+		tracer.StartSpan("child", opentracing.ChildOf(parent.Context())).Finish()
+	}).Finish()
+	require.Equal(t, []string{"keep server", "child"}, reported())
+
+	// Errors anywhere in the request keep it.
+	request("drop", func(parent opentracing.Span) {
+		child := tracer.StartSpan("failed child", opentracing.ChildOf(parent.Context()))
+		ext.Error.Set(child, true)
+		child.Finish()
+	}).Finish()
+	require.Equal(t, []string{"drop server", "failed child"}, reported())
+
+	// So do slow requests, and spans which end after the decision.
+	var late opentracing.Span
+	sp = request("drop", func(parent opentracing.Span) {
+		late = tracer.StartSpan("late child", opentracing.ChildOf(parent.Context()))
+	})
+	sp.FinishWithOptions(opentracing.FinishOptions{FinishTime: time.Now().Add(2 * time.Second)})
+	late.Finish()
+	require.Equal(t, []string{"drop server", "late child"}, reported())
+
+	// Spans still waiting when closed are decided by the default rate.
+	request("keep", func(parent opentracing.Span) {
+		tracer.StartSpan("orphan", opentracing.ChildOf(parent.Context())).Finish()
+	})
+	require.NoError(t, closer.Close())
+	require.Empty(t, reported())
+}
+
+func TestNewFromEnvWithSampling(t *testing.T) {
+	t.Setenv("JAEGER_AGENT_HOST", "localhost")
+	closer, err := NewFromEnvWithSampling("test", SamplingConfig{Rate: 1})
+	require.NoError(t, err)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	sp := opentracing.StartSpan("test")
+	require.True(t, sp.Context().(jaeger.SpanContext).IsSampled())
+	sp.Finish()
+	require.NoError(t, closer.Close())
+}
@@ -3,12 +3,14 @@ package tracing
 import (
 	"context"
 	"io"
+	"sync"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	jaeger "github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 	jaegerprom "github.com/uber/jaeger-lib/metrics/prometheus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrInvalidConfiguration is an error to notify client to provide valid trace report agent or config server
@@ -36,44 +38,91 @@ func installJaeger(serviceName string, cfg *jaegercfg.Configuration, options ...
 // Tracing will be enabled if one (or more) of the following environment variables is used to configure trace reporting:
 // - JAEGER_AGENT_HOST
 // - JAEGER_SAMPLER_MANAGER_HOST_PORT
+//
+// OTEL_PROPAGATORS, if set, lists the formats trace context is propagated
+// in; see Propagators. Otherwise only Jaeger's own format is used.
 func NewFromEnv(serviceName string, options ...jaegercfg.Option) (io.Closer, error) {
+	cfg, options, err := configFromEnv(options)
+	if err != nil {
+		return nil, err
+	}
+	return installJaeger(serviceName, cfg, options...)
+}
+
+// configFromEnv loads the Jaeger configuration from environment variables,
+// and adds the options for the propagators they configure to options.
+func configFromEnv(options []jaegercfg.Option) (*jaegercfg.Configuration, []jaegercfg.Option, error) {
 	cfg, err := jaegercfg.FromEnv()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not load jaeger tracer configuration")
+		return nil, nil, errors.Wrap(err, "could not load jaeger tracer configuration")
 	}
 
 	if cfg.Sampler.SamplingServerURL == "" && cfg.Reporter.LocalAgentHostPort == "" && cfg.Reporter.CollectorEndpoint == "" {
-		return nil, ErrBlankTraceConfiguration
+		return nil, nil, ErrBlankTraceConfiguration
 	}
 
-	return installJaeger(serviceName, cfg, options...)
+	propagators, ok, err := propagatorsFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		options = append(propagators.JaegerOptions(), options...)
+	}
+	return cfg, options, nil
 }
 
 // ExtractTraceID extracts the trace id, if any from the context.
 func ExtractTraceID(ctx context.Context) (string, bool) {
-	sp := opentracing.SpanFromContext(ctx)
-	if sp == nil {
-		return "", false
-	}
-	sctx, ok := sp.Context().(jaeger.SpanContext)
-	if !ok {
-		return "", false
-	}
-
-	return sctx.TraceID().String(), true
+	traceID, _, ok := extractTraceID(ctx)
+	return traceID, ok
 }
 
 // ExtractSampledTraceID works like ExtractTraceID but the returned bool is only
 // true if the returned trace id is sampled.
 func ExtractSampledTraceID(ctx context.Context) (string, bool) {
-	sp := opentracing.SpanFromContext(ctx)
-	if sp == nil {
-		return "", false
+	traceID, sampled, _ := extractTraceID(ctx)
+	return traceID, sampled
+}
+
+// TraceIDExtractor returns the trace id of a span context made by some other
+// OpenTracing implementation, and whether it is sampled. ok is false if the
+// span context isn't one it knows.
+type TraceIDExtractor func(opentracing.SpanContext) (traceID string, sampled, ok bool)
+
+var (
+	traceIDExtractorsMtx sync.RWMutex
+	traceIDExtractors    []TraceIDExtractor
+)
+
+// RegisterTraceIDExtractor makes ExtractTraceID and ExtractSampledTraceID
+// understand the span contexts of an OpenTracing implementation other than
+// Jaeger, like the recorder in tracingtest.
+func RegisterTraceIDExtractor(e TraceIDExtractor) {
+	traceIDExtractorsMtx.Lock()
+	defer traceIDExtractorsMtx.Unlock()
+	traceIDExtractors = append(traceIDExtractors, e)
+}
+
+// extractTraceID finds the trace id of the span in the context, whether it
+// is a Jaeger span, or an OpenTelemetry one, directly or through the
+// OpenTracing bridge, or one known to a registered TraceIDExtractor.
+func extractTraceID(ctx context.Context) (traceID string, sampled, ok bool) {
+	if sp := opentracing.SpanFromContext(ctx); sp != nil {
+		if sctx, isJaeger := sp.Context().(jaeger.SpanContext); isJaeger {
+			return sctx.TraceID().String(), sctx.IsSampled(), true
+		}
+		traceIDExtractorsMtx.RLock()
+		defer traceIDExtractorsMtx.RUnlock()
+		for _, extract := range traceIDExtractors {
+			if traceID, sampled, ok := extract(sp.Context()); ok {
+				return traceID, sampled, true
+			}
+		}
 	}
-	sctx, ok := sp.Context().(jaeger.SpanContext)
-	if !ok {
-		return "", false
+	// The bridge puts the OpenTelemetry span in the context alongside the
+	// OpenTracing one.
+	if sctx := trace.SpanContextFromContext(ctx); sctx.IsValid() {
+		return sctx.TraceID().String(), sctx.IsSampled(), true
 	}
-
-	return sctx.TraceID().String(), sctx.IsSampled()
+	return "", false, false
 }
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	jaeger "github.com/uber/jaeger-client-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestExtractTraceIDJaeger(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(false), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	_, ok := ExtractTraceID(context.Background())
+	require.False(t, ok)
+
+	sp := tracer.StartSpan("test")
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+	traceID, ok := ExtractTraceID(ctx)
+	require.True(t, ok)
+	require.Equal(t, sp.Context().(jaeger.SpanContext).TraceID().String(), traceID)
+	_, sampled := ExtractSampledTraceID(ctx)
+	require.False(t, sampled)
+}
+
+func TestExtractTraceIDOTel(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	closer, err := installOTel("test", Propagators{PropagatorTraceContext, PropagatorBaggage}, tracetest.NewNoopExporter(), sdktrace.WithSpanProcessor(recorder), sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	require.NoError(t, err)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	sp, ctx := opentracing.StartSpanFromContext(context.Background(), "test")
+	traceID, ok := ExtractSampledTraceID(ctx)
+	require.True(t, ok)
+	require.Len(t, traceID, 32)
+	sp.Finish()
+
+	require.NoError(t, closer.Close())
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "test", spans[0].Name())
+	require.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+	serviceName, ok := spans[0].Resource().Set().Value(semconv.ServiceNameKey)
+	require.True(t, ok)
+	require.Equal(t, "test", serviceName.AsString())
+}
+
+func TestRegisterTraceIDExtractor(t *testing.T) {
+	sp := mocktracer.New().StartSpan("test")
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+	_, ok := ExtractTraceID(ctx)
+	require.False(t, ok)
+
+	RegisterTraceIDExtractor(func(sc opentracing.SpanContext) (string, bool, bool) {
+		sctx, ok := sc.(mocktracer.MockSpanContext)
+		if !ok {
+			return "", false, false
+		}
+		return strconv.FormatUint(uint64(sctx.TraceID), 16), sctx.Sampled, true
+	})
+	traceID, ok := ExtractSampledTraceID(ctx)
+	require.True(t, ok)
+	require.Equal(t, strconv.FormatUint(uint64(sp.Context().(mocktracer.MockSpanContext).TraceID), 16), traceID)
+}
@@ -0,0 +1,158 @@
+// Package tracingtest records the spans made by code under test, so tests
+// can check them.
+package tracingtest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weaveworks/common/tracing"
+)
+
+func init() {
+	tracing.RegisterTraceIDExtractor(func(sc opentracing.SpanContext) (string, bool, bool) {
+		sctx, ok := sc.(mocktracer.MockSpanContext)
+		if !ok {
+			return "", false, false
+		}
+		return strconv.FormatUint(uint64(sctx.TraceID), 16), sctx.Sampled, true
+	})
+}
+
+// Recorder is an OpenTracing tracer which keeps the spans it makes in
+// memory. Every span is sampled, and this package registers a
+// tracing.TraceIDExtractor for them, so tracing.ExtractSampledTraceID, and
+// hence exemplars, work with it. It injects and extracts span contexts in HTTP
+// headers and gRPC metadata, so traces follow requests between servers in the
+// same test.
+type Recorder struct {
+	*mocktracer.MockTracer
+}
+
+// NewRecorder makes a Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{MockTracer: mocktracer.New()}
+}
+
+// Install makes a new Recorder the global tracer until the test ends, so
+// middleware.Tracer, instrument.CollectedRequest and the rest record spans
+// with it.
+func Install(t testing.TB) *Recorder {
+	r := NewRecorder()
+	previous := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(r)
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(previous)
+	})
+	return r
+}
+
+// Extract implements opentracing.Tracer. Unlike mocktracer, it returns no
+// span context when there is none, rather than an empty one which would be
+// taken as the parent of new spans.
+func (r *Recorder) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	sc, err := r.MockTracer.Extract(format, carrier)
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// SpanNames returns the names of the finished spans, in the order they
+// finished.
+func (r *Recorder) SpanNames() []string {
+	var names []string
+	for _, sp := range r.FinishedSpans() {
+		names = append(names, sp.OperationName)
+	}
+	return names
+}
+
+// Spans returns the finished spans with the given name.
+func (r *Recorder) Spans(name string) []*mocktracer.MockSpan {
+	var spans []*mocktracer.MockSpan
+	for _, sp := range r.FinishedSpans() {
+		if sp.OperationName == name {
+			spans = append(spans, sp)
+		}
+	}
+	return spans
+}
+
+// Span returns the finished span with the given name, failing the test
+// unless there is exactly one.
+func (r *Recorder) Span(t testing.TB, name string) *mocktracer.MockSpan {
+	t.Helper()
+	spans := r.Spans(name)
+	require.Len(t, spans, 1, "spans named %q, of %v", name, r.SpanNames())
+	return spans[0]
+}
+
+// Parent returns the finished parent of the span, or nil if it has none.
+func (r *Recorder) Parent(sp *mocktracer.MockSpan) *mocktracer.MockSpan {
+	for _, parent := range r.FinishedSpans() {
+		if isChildOf(sp, parent) {
+			return parent
+		}
+	}
+	return nil
+}
+
+// Children returns the finished children of the span.
+func (r *Recorder) Children(sp *mocktracer.MockSpan) []*mocktracer.MockSpan {
+	var children []*mocktracer.MockSpan
+	for _, child := range r.FinishedSpans() {
+		if isChildOf(child, sp) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// RequireSpanNames checks the names of the finished spans, in the order they
+// finished.
+func (r *Recorder) RequireSpanNames(t testing.TB, names ...string) {
+	t.Helper()
+	require.Equal(t, names, r.SpanNames())
+}
+
+// RequireTag checks the span has the tag with the value.
+func RequireTag(t testing.TB, sp *mocktracer.MockSpan, key string, value interface{}) {
+	t.Helper()
+	tags := sp.Tags()
+	require.Contains(t, tags, key, "tags of span %q", sp.OperationName)
+	require.Equal(t, value, tags[key], "tag %q of span %q", key, sp.OperationName)
+}
+
+// RequireNoTag checks the span doesn't have the tag.
+func RequireNoTag(t testing.TB, sp *mocktracer.MockSpan, key string) {
+	t.Helper()
+	require.NotContains(t, sp.Tags(), key, "tags of span %q", sp.OperationName)
+}
+
+// RequireChildOf checks child is a child of parent, in the same trace.
+func RequireChildOf(t testing.TB, child, parent *mocktracer.MockSpan) {
+	t.Helper()
+	require.True(t, isChildOf(child, parent), "span %q is not a child of %q", child.OperationName, parent.OperationName)
+}
+
+// RequireError checks the span is marked as having failed.
+func RequireError(t testing.TB, sp *mocktracer.MockSpan) {
+	t.Helper()
+	require.Equal(t, true, sp.Tag(string(ext.Error)), "span %q has no error", sp.OperationName)
+}
+
+// RequireNoError checks the span isn't marked as having failed.
+func RequireNoError(t testing.TB, sp *mocktracer.MockSpan) {
+	t.Helper()
+	require.NotEqual(t, true, sp.Tag(string(ext.Error)), "span %q has an error", sp.OperationName)
+}
+
+func isChildOf(child, parent *mocktracer.MockSpan) bool {
+	return child.ParentID == parent.SpanContext.SpanID && child.SpanContext.TraceID == parent.SpanContext.TraceID
+}
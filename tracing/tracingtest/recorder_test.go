@@ -0,0 +1,68 @@
+package tracingtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weaveworks/common/instrument"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/user"
+)
+
+func TestRecorder(t *testing.T) {
+	r := Install(t)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "request_duration_seconds",
+	}, instrument.HistogramCollectorBuckets)
+	collector := instrument.NewHistogramCollector(duration)
+
+	var traceID string
+	handler := middleware.Tracer{}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := user.InjectOrgID(req.Context(), "team1")
+		traceID, _ = tracing.ExtractSampledTraceID(ctx)
+		_ = instrument.CollectedRequest(ctx, "ok", collector, nil, func(context.Context) error {
+			return nil
+		})
+		err := instrument.CollectedRequest(ctx, "fail", collector, nil, func(context.Context) error {
+			return errors.New("fail")
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	r.RequireSpanNames(t, "ok", "fail", "HTTP GET")
+	root := r.Span(t, "HTTP GET")
+	require.Nil(t, r.Parent(root))
+	require.Len(t, r.Children(root), 2)
+	RequireError(t, root)
+
+	ok := r.Span(t, "ok")
+	RequireChildOf(t, ok, root)
+	RequireTag(t, ok, user.OrgIDSpanTag, "team1")
+	RequireNoTag(t, ok, user.UserIDSpanTag)
+	RequireNoError(t, ok)
+
+	fail := r.Span(t, "fail")
+	RequireChildOf(t, fail, root)
+	RequireError(t, fail)
+
+	// The trace ID is extracted, and used for exemplars.
+	require.NotEmpty(t, traceID)
+	var metric dto.Metric
+	require.NoError(t, duration.WithLabelValues("ok", "200").(prometheus.Histogram).Write(&metric))
+	var exemplarTraceIDs []string
+	for _, b := range metric.Histogram.Bucket {
+		if b.Exemplar != nil {
+			exemplarTraceIDs = append(exemplarTraceIDs, b.Exemplar.Label[0].GetValue())
+		}
+	}
+	require.Equal(t, []string{traceID}, exemplarTraceIDs)
+}
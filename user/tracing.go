@@ -0,0 +1,60 @@
+package user
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context"
+)
+
+// Span tags and baggage items for the org and user IDs.
+const (
+	OrgIDSpanTag  = "organization"
+	UserIDSpanTag = "user"
+
+	// Baggage keys are lower case, as Jaeger lower cases them in HTTP headers.
+	OrgIDBaggageItem  = "org_id"
+	UserIDBaggageItem = "user_id"
+)
+
+// TagSpan tags the span with the org and user IDs, if they are not empty. If
+// baggage is true, they are also put in the span's baggage, so they are sent
+// on with the trace to services it calls.
+func TagSpan(sp opentracing.Span, orgID, userID string, baggage bool) {
+	if orgID != "" {
+		sp.SetTag(OrgIDSpanTag, orgID)
+		if baggage {
+			sp.SetBaggageItem(OrgIDBaggageItem, orgID)
+		}
+	}
+	if userID != "" {
+		sp.SetTag(UserIDSpanTag, userID)
+		if baggage {
+			sp.SetBaggageItem(UserIDBaggageItem, userID)
+		}
+	}
+}
+
+// ExtractOrgIDFromSpan gets the org ID from the baggage of the span in the
+// context, for when a request has lost its header on the way.
+func ExtractOrgIDFromSpan(ctx context.Context) (string, error) {
+	if orgID := baggageItem(ctx, OrgIDBaggageItem); orgID != "" {
+		return orgID, nil
+	}
+	return "", ErrNoOrgID
+}
+
+// ExtractUserIDFromSpan gets the user ID from the baggage of the span in the
+// context.
+func ExtractUserIDFromSpan(ctx context.Context) (string, error) {
+	if userID := baggageItem(ctx, UserIDBaggageItem); userID != "" {
+		return userID, nil
+	}
+	return "", ErrNoUserID
+}
+
+func baggageItem(ctx context.Context, key string) string {
+	sp := opentracing.SpanFromContext(ctx)
+	if sp == nil {
+		return ""
+	}
+	return sp.BaggageItem(key)
+}